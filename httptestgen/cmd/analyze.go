@@ -0,0 +1,275 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const (
+	jsonDecodeFunc      = "(*encoding/json.Decoder).Decode"
+	jsonEncodeFunc      = "(*encoding/json.Encoder).Encode"
+	jsonUnmarshalFunc   = "encoding/json.Unmarshal"
+	writeHeaderFunc     = "(net/http.ResponseWriter).WriteHeader"
+	responseWriterType  = "net/http.ResponseWriter"
+	requestPointerType  = "*net/http.Request"
+	defaultResponseCode = http.StatusOK
+)
+
+// HandlerInfo contains the request and response types discovered by analyzing a handler body
+type HandlerInfo struct {
+	Name        string
+	RequestType string
+	// ResponseTypes maps status codes to the type encoded in the response
+	ResponseTypes map[string]string
+}
+
+// analyzeHandlers discovers the request and response types of the http handlers declared in a file.
+// Handlers declared as methods are keyed by the name of their receiver type and their name, e.g. "Server.GetUser".
+// The analysis is best effort: types that cannot be resolved, or that are ambiguous, are not reported.
+func analyzeHandlers(file *ast.File, pkg *types.Package, info *types.Info, qualifier types.Qualifier) map[string]HandlerInfo {
+	handlers := make(map[string]HandlerInfo)
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+
+		obj, ok := info.Defs[funcDecl.Name].(*types.Func)
+		if !ok || !isHandlerSignature(obj.Type().(*types.Signature)) {
+			continue
+		}
+
+		name := funcDecl.Name.Name
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			named, ok := types.Unalias(derefType(recv.Type())).(*types.Named)
+			if !ok {
+				continue
+			}
+			name = named.Obj().Name() + "." + name
+		}
+
+		a := handlerAnalyzer{
+			pkg:           pkg,
+			info:          info,
+			qualifier:     qualifier,
+			responseTypes: make(map[string]map[string]struct{}),
+			requestTypes:  make(map[string]struct{}),
+		}
+		a.walkStmts(funcDecl.Body.List, defaultResponseCode)
+
+		handlers[name] = HandlerInfo{
+			Name:          name,
+			RequestType:   uniqueType(a.requestTypes),
+			ResponseTypes: a.uniqueResponseTypes(),
+		}
+	}
+
+	return handlers
+}
+
+// isHandlerSignature reports whether a signature is compatible with http.HandlerFunc
+func isHandlerSignature(sig *types.Signature) bool {
+	params := sig.Params()
+	return sig.Results().Len() == 0 &&
+		params.Len() == 2 &&
+		types.TypeString(params.At(0).Type(), nil) == responseWriterType &&
+		types.TypeString(params.At(1).Type(), nil) == requestPointerType
+}
+
+// handlerAnalyzer tracks the status code written along each code path of a handler
+// and collects the types decoded from the request and encoded in the response.
+type handlerAnalyzer struct {
+	pkg           *types.Package
+	info          *types.Info
+	qualifier     types.Qualifier
+	requestTypes  map[string]struct{}
+	responseTypes map[string]map[string]struct{}
+}
+
+// walkStmts walks a list of statements in order.
+// Status codes written in nested blocks do not leak to the statements following the block.
+func (a *handlerAnalyzer) walkStmts(stmts []ast.Stmt, status int) int {
+	for _, stmt := range stmts {
+		status = a.walkStmt(stmt, status)
+	}
+	return status
+}
+
+func (a *handlerAnalyzer) walkStmt(stmt ast.Stmt, status int) int {
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		a.walkStmts(s.List, status)
+	case *ast.IfStmt:
+		status = a.walkNode(s.Init, status)
+		status = a.walkNode(s.Cond, status)
+		a.walkStmts(s.Body.List, status)
+		if s.Else != nil {
+			a.walkStmt(s.Else, status)
+		}
+	case *ast.ForStmt:
+		status = a.walkNode(s.Init, status)
+		a.walkStmts(s.Body.List, status)
+	case *ast.RangeStmt:
+		a.walkStmts(s.Body.List, status)
+	case *ast.SwitchStmt:
+		status = a.walkNode(s.Init, status)
+		status = a.walkNode(s.Tag, status)
+		a.walkStmts(s.Body.List, status)
+	case *ast.TypeSwitchStmt:
+		status = a.walkNode(s.Init, status)
+		a.walkStmts(s.Body.List, status)
+	case *ast.SelectStmt:
+		a.walkStmts(s.Body.List, status)
+	case *ast.CaseClause:
+		a.walkStmts(s.Body, status)
+	case *ast.CommClause:
+		a.walkStmts(s.Body, status)
+	case *ast.LabeledStmt:
+		status = a.walkStmt(s.Stmt, status)
+	default:
+		status = a.walkNode(stmt, status)
+	}
+	return status
+}
+
+// walkNode inspects the calls made by a node that does not open a new block
+func (a *handlerAnalyzer) walkNode(node ast.Node, status int) int {
+	if node == nil {
+		return status
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			a.walkStmts(n.Body.List, status)
+			return false
+		case *ast.CallExpr:
+			status = a.visitCall(n, status)
+		}
+		return true
+	})
+
+	return status
+}
+
+func (a *handlerAnalyzer) visitCall(call *ast.CallExpr, status int) int {
+	fn := a.calledFunc(call)
+	if fn == nil {
+		return status
+	}
+
+	switch fn.FullName() {
+	case writeHeaderFunc:
+		if len(call.Args) == 1 {
+			if code, ok := a.constantInt(call.Args[0]); ok {
+				return code
+			}
+		}
+	case jsonEncodeFunc:
+		if len(call.Args) == 1 {
+			if t := a.namedType(call.Args[0]); t != "" {
+				key := strconv.Itoa(status)
+				if a.responseTypes[key] == nil {
+					a.responseTypes[key] = make(map[string]struct{})
+				}
+				a.responseTypes[key][t] = struct{}{}
+			}
+		}
+	case jsonDecodeFunc:
+		if len(call.Args) == 1 {
+			if t := a.namedType(call.Args[0]); t != "" {
+				a.requestTypes[t] = struct{}{}
+			}
+		}
+	case jsonUnmarshalFunc:
+		if len(call.Args) == 2 {
+			if t := a.namedType(call.Args[1]); t != "" {
+				a.requestTypes[t] = struct{}{}
+			}
+		}
+	}
+
+	return status
+}
+
+// calledFunc returns the function or method called by a call expression
+func (a *handlerAnalyzer) calledFunc(call *ast.CallExpr) *types.Func {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return nil
+	}
+
+	fn, _ := a.info.Uses[ident].(*types.Func)
+	return fn
+}
+
+// constantInt returns the value of an integer constant expression such as http.StatusCreated
+func (a *handlerAnalyzer) constantInt(expr ast.Expr) (int, bool) {
+	tv, ok := a.info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return 0, false
+	}
+
+	v, ok := constant.Int64Val(tv.Value)
+	return int(v), ok
+}
+
+// namedType returns the name of the type of an expression, dereferencing pointers,
+// if it is a named type declared in the analyzed package or in one of the packages it imports.
+func (a *handlerAnalyzer) namedType(expr ast.Expr) string {
+	t := a.info.TypeOf(expr)
+	if t == nil {
+		return ""
+	}
+
+	named, ok := types.Unalias(derefType(t)).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+
+	if typePkg := named.Obj().Pkg(); typePkg != a.pkg && !slices.Contains(a.pkg.Imports(), typePkg) {
+		return ""
+	}
+
+	return types.TypeString(named, a.qualifier)
+}
+
+// derefType returns the element type of pointers, and other types as they are
+func derefType(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+// uniqueResponseTypes returns the response types for the status codes that encode a single type
+func (a *handlerAnalyzer) uniqueResponseTypes() map[string]string {
+	responseTypes := make(map[string]string, len(a.responseTypes))
+	for status, ts := range a.responseTypes {
+		if t := uniqueType(ts); t != "" {
+			responseTypes[status] = t
+		}
+	}
+	return responseTypes
+}
+
+// uniqueType returns the only type in a set, or an empty string if the set is empty or ambiguous
+func uniqueType(ts map[string]struct{}) string {
+	if len(ts) != 1 {
+		return ""
+	}
+
+	for t := range ts {
+		return t
+	}
+	return ""
+}
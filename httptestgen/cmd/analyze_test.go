@@ -0,0 +1,104 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+const handlersSrc = `package handler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type (
+	CreateRequest  struct{ Name string }
+	CreateResponse struct{ ID int }
+	ErrorResponse  struct{ Error string }
+)
+
+func CreateHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	w.WriteHeader(http.StatusCreated)
+	_ = enc.Encode(&CreateResponse{ID: 1})
+}
+
+func ListHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(CreateResponse{})
+}
+
+func AmbiguousHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("error") {
+		json.NewEncoder(w).Encode(ErrorResponse{})
+		return
+	}
+	json.NewEncoder(w).Encode(CreateResponse{})
+}
+
+type Store struct{}
+
+func (s *Store) GetHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(CreateResponse{})
+}
+
+func notAHandler(r *http.Request) {}
+`
+
+func TestAnalyzeHandlers(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "handler.go", handlersSrc, 0)
+	if err != nil {
+		t.Fatalf("could not parse source: %v", err)
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("handler", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatalf("could not type check source: %v", err)
+	}
+
+	expected := map[string]HandlerInfo{
+		"CreateHandler": {
+			Name:        "CreateHandler",
+			RequestType: "CreateRequest",
+			ResponseTypes: map[string]string{
+				"400": "ErrorResponse",
+				"201": "CreateResponse",
+			},
+		},
+		"ListHandler": {
+			Name:          "ListHandler",
+			ResponseTypes: map[string]string{"200": "CreateResponse"},
+		},
+		"AmbiguousHandler": {
+			Name:          "AmbiguousHandler",
+			ResponseTypes: map[string]string{},
+		},
+		"Store.GetHandler": {
+			Name:          "Store.GetHandler",
+			ResponseTypes: map[string]string{"202": "CreateResponse"},
+		},
+	}
+
+	if got := analyzeHandlers(file, pkg, info, packageQualifier(pkg)); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("unexpected handlers:\ngot:  %+v\nwant: %+v", got, expected)
+	}
+}
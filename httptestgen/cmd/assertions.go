@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// assertionOps are the operators of assertions
+var assertionOps = []string{"==", "!=", ">", ">=", "<", "<=", "contains", "length", "exists"}
+
+// Assertion checks the value at a JSON path of a response body, e.g. {"path": "$.user.id", "op": ">", "value": 0}.
+// Value is the operand of the operator, kept as written so that it is generated exactly.
+type Assertion struct {
+	Path  string          `json:"path"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// assertionCodes checks the assertions of a response and generates the jsonAssertion literals checking them
+func assertionCodes(assertions []Assertion) ([]string, error) {
+	codes := make([]string, 0, len(assertions))
+	for i, assertion := range assertions {
+		operand, err := assertionOperand(assertion)
+		if err != nil {
+			return nil, fmt.Errorf("assertion %d: %w", i, err)
+		}
+		codes = append(codes, fmt.Sprintf("{Path: %s, Op: %s, Value: %s}",
+			strconv.Quote(assertion.Path), strconv.Quote(assertion.Op), rawStringCode(operand)))
+	}
+	return codes, nil
+}
+
+// assertionOperand checks an assertion and returns its compacted operand, true for exists assertions declaring none
+func assertionOperand(assertion Assertion) (string, error) {
+	segments, err := parseJSONPath(assertion.Path)
+	if err != nil {
+		return "", err
+	}
+	if slices.ContainsFunc(segments, func(segment string) bool { return segment == "*" || segment == "[*]" }) {
+		return "", fmt.Errorf("%s: assertions need a path to a single value, without wildcards", assertion.Path)
+	}
+	if !slices.Contains(assertionOps, assertion.Op) {
+		return "", fmt.Errorf("%s: unknown operator %q, want one of %s", assertion.Path, assertion.Op, strings.Join(assertionOps, ", "))
+	}
+
+	if len(assertion.Value) == 0 {
+		if assertion.Op != "exists" {
+			return "", fmt.Errorf("%s: %s needs a value", assertion.Path, assertion.Op)
+		}
+		return "true", nil
+	}
+
+	var operand bytes.Buffer
+	if err := json.Compact(&operand, assertion.Value); err != nil {
+		return "", fmt.Errorf("%s: invalid value: %w", assertion.Path, err)
+	}
+
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(assertion.Value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("%s: invalid value: %w", assertion.Path, err)
+	}
+
+	switch assertion.Op {
+	case ">", ">=", "<", "<=":
+		if _, ok := value.(json.Number); !ok {
+			return "", fmt.Errorf("%s: %s expects a number, got %s", assertion.Path, assertion.Op, operand.String())
+		}
+	case "length":
+		if n, err := strconv.Atoi(operand.String()); err != nil || n < 0 {
+			return "", fmt.Errorf("%s: length expects a non negative integer, got %s", assertion.Path, operand.String())
+		}
+	case "exists":
+		if _, ok := value.(bool); !ok {
+			return "", fmt.Errorf("%s: exists expects a boolean, got %s", assertion.Path, operand.String())
+		}
+	}
+	return operand.String(), nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAssertionCodes(t *testing.T) {
+	decodeAssertions := func(t *testing.T, src string) []Assertion {
+		t.Helper()
+		var assertions []Assertion
+		if err := json.Unmarshal([]byte(src), &assertions); err != nil {
+			t.Fatalf("could not decode assertions: %v", err)
+		}
+		return assertions
+	}
+
+	t.Run("it should generate assertions with their operand as written", func(t *testing.T) {
+		codes, err := assertionCodes(decodeAssertions(t, `[
+			{"path": "$.user.id", "op": ">", "value": 0},
+			{"path": "$.items", "op": "length", "value": 3},
+			{"path": "$.tags", "op": "contains", "value": "admin"},
+			{"path": "$.user", "op": "==", "value": {"id": 9007199254740993, "name": "Andrea"}},
+			{"path": "$.items[0].deleted_at", "op": "exists", "value": false},
+			{"path": "$.user.email", "op": "exists"}
+		]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"{Path: \"$.user.id\", Op: \">\", Value: `0`}",
+			"{Path: \"$.items\", Op: \"length\", Value: `3`}",
+			"{Path: \"$.tags\", Op: \"contains\", Value: `\"admin\"`}",
+			"{Path: \"$.user\", Op: \"==\", Value: `{\"id\":9007199254740993,\"name\":\"Andrea\"}`}",
+			"{Path: \"$.items[0].deleted_at\", Op: \"exists\", Value: `false`}",
+			"{Path: \"$.user.email\", Op: \"exists\", Value: `true`}",
+		}
+		if !slices.Equal(codes, expected) {
+			t.Errorf("unexpected assertions:\ngot:  %q\nwant: %q", codes, expected)
+		}
+	})
+
+	t.Run("it should reject invalid assertions", func(t *testing.T) {
+		for src, expected := range map[string]string{
+			`[{"path": "user.id", "op": "=="}]`:                   `assertion 0: invalid JSON path "user.id"`,
+			`[{"path": "$.items[*].id", "op": "exists"}]`:         "$.items[*].id: assertions need a path to a single value, without wildcards",
+			`[{"path": "$.id", "op": "=~", "value": 1}]`:          `$.id: unknown operator "=~"`,
+			`[{"path": "$.id", "op": "=="}]`:                      "$.id: == needs a value",
+			`[{"path": "$.id", "op": "<", "value": "1"}]`:         `$.id: < expects a number, got "1"`,
+			`[{"path": "$.items", "op": "length", "value": 1.5}]`: "$.items: length expects a non negative integer, got 1.5",
+			`[{"path": "$.id", "op": "exists", "value": 1}]`:      "$.id: exists expects a boolean, got 1",
+		} {
+			_, err := assertionCodes(decodeAssertions(t, src))
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected an error containing %q, got %v", src, expected, err)
+			}
+		}
+	})
+}
+
+func TestRenderAssertions(t *testing.T) {
+	t.Run("it should check assertions after comparing the body", func(t *testing.T) {
+		var testCase TestCase
+		if err := json.Unmarshal([]byte(`{
+			"case_descr": "it should return users",
+			"response": {"status_code": 200, "body": {"name": "Andrea"}}
+		}`), &testCase); err != nil {
+			t.Fatalf("could not decode test case: %v", err)
+		}
+
+		spec := GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{{
+				Func: "GetUser",
+				TestCases: []EnhancedTestCase{{
+					TestCase:   testCase,
+					Assertions: []string{"{Path: \"$.id\", Op: \">\", Value: `0`}"},
+				}},
+			}},
+		}
+		if !spec.UsesAssertions() || spec.UsesBodyMatch() {
+			t.Fatal("expected the spec to use assertions only")
+		}
+
+		src, err := renderTests(spec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body := string(src)
+		for _, expected := range []string{
+			"for _, problem := range assertJSON(rr.Body.Bytes(), []jsonAssertion{\n\t\t\t{Path: \"$.id\", Op: \">\", Value: `0`},\n\t\t}) {",
+			`t.Errorf("GetUser returned unexpected body: %s", problem)`,
+			"func jsonText(v any) string {",
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected the tests to contain %q:\n%s", expected, src)
+			}
+		}
+		if strings.Index(body, "expectedBody :=") > strings.Index(body, "assertJSON(rr") {
+			t.Errorf("expected the body to be compared before the assertions are checked:\n%s", src)
+		}
+	})
+}
+
+func TestAssertJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+
+	runAssertJSON := func(t *testing.T, body, assertion string) string {
+		t.Helper()
+		return runHelpers(t, EnhancedTestCase{Assertions: []string{assertion}}, "// jsonAssertion checks", fmt.Sprintf(`func main() {
+	fmt.Print(strings.Join(assertJSON([]byte(%q), []jsonAssertion{%s}), "\n"))
+}`, body, assertion))
+	}
+
+	for _, tt := range []struct {
+		name      string
+		body      string
+		assertion string
+		problems  string
+	}{
+		{
+			name:      "it should compare integers beyond the precision of float64 exactly",
+			body:      `{"id":9007199254740992}`,
+			assertion: "{Path: \"$.id\", Op: \"==\", Value: `9007199254740993`}",
+			problems:  "$.id: got 9007199254740992, want == 9007199254740993",
+		},
+		{
+			name:      "it should order integers beyond the precision of float64 exactly",
+			body:      `{"id":9007199254740992}`,
+			assertion: "{Path: \"$.id\", Op: \">=\", Value: `9007199254740993`}",
+			problems:  "$.id: got 9007199254740992, want >= 9007199254740993",
+		},
+		{
+			name:      "it should tell apart integers beyond the precision of float64",
+			body:      `{"id":9007199254740992}`,
+			assertion: "{Path: \"$.id\", Op: \"!=\", Value: `9007199254740993`}",
+		},
+		{
+			name:      "it should look for integers beyond the precision of float64 exactly",
+			body:      `{"ids":[9007199254740992]}`,
+			assertion: "{Path: \"$.ids\", Op: \"contains\", Value: `9007199254740993`}",
+			problems:  "$.ids: got [9007199254740992], want contains 9007199254740993",
+		},
+		{
+			name:      "it should compare numbers by value whatever their notation",
+			body:      `{"user":{"score":1.50,"visits":1e2}}`,
+			assertion: "{Path: \"$.user\", Op: \"==\", Value: `{\"score\":1.5,\"visits\":100}`}",
+		},
+		{
+			name:      "it should compare lengths",
+			body:      `{"ids":[1,2,3]}`,
+			assertion: "{Path: \"$.ids\", Op: \"length\", Value: `2`}",
+			problems:  "$.ids: got length 3, want length 2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runAssertJSON(t, tt.body, tt.assertion); got != tt.problems {
+				t.Errorf("unexpected problems:\ngot:  %s\nwant: %s", got, tt.problems)
+			}
+		})
+	}
+}
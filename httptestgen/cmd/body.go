@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// UnmarshalJSON decodes a request declared in a spec, building its URL and keeping its body as written
+// so that bodies which do not fit the request type can be sent as they are
+func (r *Request) UnmarshalJSON(data []byte) error {
+	// request has the fields of Request without its methods, to avoid recursing
+	type request Request
+
+	var raw struct {
+		request
+		Body json.RawMessage `json:"body,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*r = Request(raw.request)
+
+	url, pathValues, err := buildURL(r.Path, r.PathParams, r.Query)
+	if err != nil {
+		return fmt.Errorf("invalid request path: %w", err)
+	}
+	r.URL, r.PathValues = url, pathValues
+
+	if len(raw.Body) == 0 || string(raw.Body) == "null" {
+		return nil
+	}
+
+	body, err := decodeBody(raw.Body)
+	if err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw.Body); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	r.Body, r.RawBody = body, compact.String()
+	return nil
+}
+
+// decodeBody decodes a JSON object body, with numbers decoded as json.Number
+// so that they are generated exactly as written
+func decodeBody(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var body map[string]any
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("body must be an object: %w", err)
+	}
+
+	return body, nil
+}
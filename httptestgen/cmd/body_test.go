@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestUnmarshalJSON(t *testing.T) {
+	t.Run("it should keep the body as written and decode numbers exactly", func(t *testing.T) {
+		var req Request
+		if err := json.Unmarshal([]byte(`{
+			"method": "POST",
+			"body": {"name": "Andrea", "id": 9007199254740993, "email": 123},
+			"headers": {"content-type": "application/json"}
+		}`), &req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expected := `{"name":"Andrea","id":9007199254740993,"email":123}`; req.RawBody != expected {
+			t.Fatalf("unexpected raw body:\ngot:  %s\nwant: %s", req.RawBody, expected)
+		}
+		if id := req.Body["id"]; id != json.Number("9007199254740993") {
+			t.Fatalf("unexpected id %v (%T)", id, id)
+		}
+		if req.Method != "POST" || req.Headers["Content-Type"] == nil {
+			t.Fatalf("unexpected request %+v", req)
+		}
+	})
+
+	t.Run("it should return an error when the body is not an object", func(t *testing.T) {
+		var req Request
+		if err := json.Unmarshal([]byte(`{"body": [1, 2]}`), &req); err == nil {
+			t.Fatal("expected an error, got none")
+		}
+	})
+}
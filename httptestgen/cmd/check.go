@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// errStaleTests is returned when the generated tests on disk differ from the ones the spec generates
+var errStaleTests = errors.New("generated tests are stale")
+
+// checkTests compares generated tests with the ones written to outputFile, printing a unified diff
+// to w when they differ. A missing output file is compared as an empty one.
+func checkTests(outputFile string, src []byte, w io.Writer) error {
+	current, err := os.ReadFile(outputFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not read %s: %w", outputFile, err)
+	}
+
+	if bytes.Equal(current, src) {
+		return nil
+	}
+
+	if diff := unifiedDiff(outputFile, outputFile+" (generated)", current, src); diff != "" {
+		fmt.Fprint(w, diff)
+	} else {
+		fmt.Fprintf(w, "%s differs in its trailing new lines\n", outputFile)
+	}
+
+	return fmt.Errorf("%w: %s, run httptestgen to regenerate it", errStaleTests, outputFile)
+}
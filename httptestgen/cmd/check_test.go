@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckTests(t *testing.T) {
+	spec := GenerationSpec{
+		PackageName: "handler",
+		FunctionSpecs: []FunctionTestSpec{{
+			Func: "PingHandler",
+			TestCases: []EnhancedTestCase{{TestCase: TestCase{
+				CaseDescr: "it should pong",
+				Request: Request{
+					Body:    map[string]any{"b": "2", "a": "1", "c": map[string]any{"z": true, "y": nil}},
+					Headers: Headers{"X-B": {"2"}, "X-A": {"1"}, "X-C": {"3"}},
+				},
+				Response: Response{
+					StatusCode: 200,
+					Headers:    ExpectedHeaders{"X-B": {"2"}, "X-A": nil},
+				},
+			}}},
+		}},
+	}
+
+	src, err := renderTests(spec)
+	if err != nil {
+		t.Fatalf("could not render tests: %v", err)
+	}
+
+	t.Run("it should render the same tests on every run", func(t *testing.T) {
+		for range 20 {
+			got, err := renderTests(spec)
+			if err != nil {
+				t.Fatalf("could not render tests: %v", err)
+			}
+			if !bytes.Equal(src, got) {
+				t.Fatalf("non deterministic tests:\n%s", unifiedDiff("first", "next", src, got))
+			}
+		}
+	})
+
+	outputFile := filepath.Join(t.TempDir(), "handler_test.go")
+
+	t.Run("it should report missing output files as stale", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := checkTests(outputFile, src, &out); !errors.Is(err, errStaleTests) {
+			t.Fatalf("expected stale tests, got: %v", err)
+		}
+	})
+
+	t.Run("it should accept up to date output files", func(t *testing.T) {
+		if err := os.WriteFile(outputFile, src, 0o644); err != nil {
+			t.Fatalf("could not write output: %v", err)
+		}
+
+		var out bytes.Buffer
+		if err := checkTests(outputFile, src, &out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.Len() > 0 {
+			t.Fatalf("unexpected output: %s", out.String())
+		}
+	})
+
+	t.Run("it should print a diff of stale output files", func(t *testing.T) {
+		stale := bytes.Replace(src, []byte("it_should_pong"), []byte("it_should_ping"), 1)
+		if err := os.WriteFile(outputFile, stale, 0o644); err != nil {
+			t.Fatalf("could not write output: %v", err)
+		}
+
+		var out bytes.Buffer
+		if err := checkTests(outputFile, src, &out); !errors.Is(err, errStaleTests) {
+			t.Fatalf("expected stale tests, got: %v", err)
+		}
+		if diff := out.String(); !strings.Contains(diff, `-	t.Run("it_should_ping"`) || !strings.Contains(diff, `+	t.Run("it_should_pong"`) {
+			t.Fatalf("unexpected diff:\n%s", diff)
+		}
+	})
+}
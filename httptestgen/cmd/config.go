@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"strings"
 )
 
@@ -11,6 +12,18 @@ type config struct {
 	outputFile    string
 	testCasesFile string
 	requestTypes  []string
+	// router is the function constructing the http.Handler serving the requests of functions not declaring one.
+	router string
+	// responseTypes maps status codes to response types, the empty status code is the default.
+	responseTypes map[string]string
+	// noVerify skips type checking the generated tests before writing them.
+	noVerify bool
+	// check compares the generated tests with the output file instead of writing them.
+	check bool
+	// force overwrites output files that were not generated by httptestgen.
+	force bool
+	// lenient reports test case bodies not matching their types as warnings instead of errors.
+	lenient bool
 }
 
 func (cfg config) validate() error {
@@ -27,19 +40,72 @@ func (cfg config) validate() error {
 
 func initConfig() (config, error) {
 	var (
-		cfg      config
-		reqTypes string
+		cfg       config
+		reqTypes  string
+		respTypes string
 	)
 
 	flag.StringVar(&cfg.inputFile, "input", "", "Input Go file to parse")
 	flag.StringVar(&cfg.outputFile, "output", "", "Output test file")
-	flag.StringVar(&cfg.testCasesFile, "testcases", "", "JSON file containing test cases (defaults to <input>_testcases.json)")
+	flag.StringVar(&cfg.testCasesFile, "testcases", "", "JSON or YAML file containing test cases (defaults to <input>_testcases.json)")
 	flag.StringVar(&reqTypes, "request-type", "", "Supported request types")
+	flag.StringVar(&cfg.router, "router", "", "Function constructing the http.Handler serving the requests, e.g. NewRouter (optional)")
+	flag.StringVar(&respTypes, "response-type", "", "Response types, either a default type or status=Type pairs (e.g. 201=CreateUserResponse,400=ErrorResponse)")
+	flag.BoolVar(&cfg.noVerify, "no-verify", false, "Write the generated tests without type checking them")
+	flag.BoolVar(&cfg.check, "check", false, "Check that the output file is up to date instead of writing it, printing a diff when it is not")
+	flag.BoolVar(&cfg.force, "force", false, "Overwrite the output file even if it was not generated by httptestgen")
+	flag.BoolVar(&cfg.lenient, "lenient", false, "Report test case body keys and fields not matching their types as warnings instead of errors")
 	flag.Parse()
 
-	for _, rt := range strings.Split(reqTypes, ",") {
-		cfg.requestTypes = append(cfg.requestTypes, strings.TrimSpace(rt))
+	cfg.requestTypes = splitList(reqTypes)
+
+	responseTypes, err := parseResponseTypes(respTypes)
+	if err != nil {
+		return cfg, err
 	}
+	cfg.responseTypes = responseTypes
 
 	return cfg, cfg.validate()
 }
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseResponseTypes parses a list of response types optionally prefixed by the status code they apply to
+func parseResponseTypes(value string) (map[string]string, error) {
+	responseTypes := make(map[string]string)
+	for _, item := range splitList(value) {
+		statusCode, typeName, found := strings.Cut(item, "=")
+		if !found {
+			statusCode, typeName = "", item
+		}
+
+		statusCode, typeName = strings.TrimSpace(statusCode), strings.TrimSpace(typeName)
+		if typeName == "" || (found && statusCode == "") {
+			return nil, fmt.Errorf("invalid response type %q", item)
+		}
+
+		if found {
+			code, err := parseStatusCode(statusCode)
+			if err != nil {
+				return nil, fmt.Errorf("invalid response type %q: %w", item, err)
+			}
+			statusCode = code.String()
+		}
+
+		if _, ok := responseTypes[statusCode]; ok {
+			return nil, fmt.Errorf("duplicate response type for status code %q", statusCode)
+		}
+
+		responseTypes[statusCode] = typeName
+	}
+	return responseTypes, nil
+}
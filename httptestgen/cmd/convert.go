@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// runConvert converts a spec between JSON and YAML, the formats being chosen by the extensions of the files.
+// Comments and anchors of YAML specs are not kept, as JSON cannot express them. Existing files are only replaced when forced.
+func runConvert(args []string, w io.Writer) error {
+	var (
+		flags         = flag.NewFlagSet("convert", flag.ContinueOnError)
+		testCasesFile = flags.String("testcases", "", "JSON or YAML file containing test cases")
+		outputFile    = flags.String("output", "", "Converted file, written as YAML when its extension is .yaml or .yml and as JSON otherwise")
+		force         = flags.Bool("force", false, "Overwrite the output file if it exists")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case *testCasesFile == "":
+		return errors.New("test cases file is required")
+	case *outputFile == "":
+		return errors.New("output file is required")
+	}
+
+	if _, err := os.Stat(*outputFile); err == nil && !*force {
+		return fmt.Errorf("%w: %s, pass -force to overwrite it", fs.ErrExist, *outputFile)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not stat %s: %w", *outputFile, err)
+	}
+
+	data, err := os.ReadFile(*testCasesFile)
+	if err != nil {
+		return fmt.Errorf("could not read test cases: %w", err)
+	}
+
+	idx, err := indexSpec(*testCasesFile, data)
+	if err != nil {
+		return fmt.Errorf("could not parse test cases: %s", syntaxDiagnostic(*testCasesFile, data, err))
+	}
+
+	out, err := convertSpec(idx.json, isYAML(*outputFile))
+	if err != nil {
+		return fmt.Errorf("could not convert test cases: %w", err)
+	}
+
+	if err := replaceFile(*outputFile, out); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Converted %s to %s\n", *testCasesFile, *outputFile)
+	return nil
+}
+
+// convertSpec formats a spec given as JSON, either as YAML or as JSON indented the way specs are written
+func convertSpec(doc []byte, toYAML bool) ([]byte, error) {
+	if toYAML {
+		return jsonToYAML(doc)
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, doc, "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes in unified diffs
+const diffContext = 3
+
+// lineEdit is a line of a diff, kept (' '), deleted ('-') or inserted ('+')
+type lineEdit struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the unified diff between two texts, or an empty string when they are equal
+func unifiedDiff(oldName, newName string, oldText, newText []byte) string {
+	edits := diffLines(splitLines(string(oldText)), splitLines(string(newText)))
+	if !slices.ContainsFunc(edits, func(e lineEdit) bool { return e.kind != ' ' }) {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		// Find the next change and extend the hunk while changes are close enough to share context
+		first := slices.IndexFunc(edits[start:], func(e lineEdit) bool { return e.kind != ' ' })
+		if first < 0 {
+			break
+		}
+		first += start
+
+		last := first
+		for i := first + 1; i < len(edits) && i-last <= 2*diffContext+1; i++ {
+			if edits[i].kind != ' ' {
+				last = i
+			}
+		}
+
+		from, to := max(first-diffContext, 0), min(last+diffContext+1, len(edits))
+
+		oldLine, newLine := 1, 1
+		for _, e := range edits[:from] {
+			if e.kind != '+' {
+				oldLine++
+			}
+			if e.kind != '-' {
+				newLine++
+			}
+		}
+
+		var oldCount, newCount int
+		for _, e := range edits[from:to] {
+			if e.kind != '+' {
+				oldCount++
+			}
+			if e.kind != '-' {
+				newCount++
+			}
+		}
+
+		// Empty ranges refer to the line before them
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, e := range edits[from:to] {
+			fmt.Fprintf(&out, "%c%s\n", e.kind, e.line)
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+// splitLines splits a text into lines, without the trailing empty line of texts ending with a new line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes the shortest edit script turning a into b with the Myers algorithm
+func diffLines(a, b []string) []lineEdit {
+	var (
+		n, m   = len(a), len(b)
+		offset = n + m + 1
+		v      = make([]int, 2*offset+1)
+		trace  [][]int
+	)
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end, collecting edits in reverse order
+	var (
+		edits []lineEdit
+		x, y  = n, m
+	)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, lineEdit{kind: ' ', line: a[x-1]})
+			x, y = x-1, y-1
+		}
+
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, lineEdit{kind: '+', line: b[y-1]})
+			} else {
+				edits = append(edits, lineEdit{kind: '-', line: a[x-1]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	slices.Reverse(edits)
+	return edits
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{
+			name: "no diff for equal texts",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+		},
+		{
+			name:     "changed lines with their context",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:      "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n",
+			expected: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "separate hunks for distant changes",
+			old:      "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			new:      "1\n2\n3\n4\n5\n6\n7\n",
+			expected: "--- old\n+++ new\n@@ -1,4 +1,3 @@\n-a\n 1\n 2\n 3\n@@ -6,4 +5,3 @@\n 5\n 6\n 7\n-b\n",
+		},
+		{
+			name:     "insertions into empty texts",
+			old:      "",
+			new:      "a\nb\n",
+			expected: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+	} {
+		t.Run("it should generate "+tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", []byte(tt.old), []byte(tt.new)); got != tt.expected {
+				t.Fatalf("unexpected diff:\ngot:\n%s\nwant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// fieldChecker collects the problems of JSON bodies that would be silently dropped when generating struct literals
+type fieldChecker struct {
+	structInfos map[string]StructInfo
+	// required reports the fields without omitempty the bodies leave out
+	required bool
+	problems []string
+}
+
+// bodyFieldProblems checks a JSON body against its struct type, and the nested objects against the types
+// of their fields. It reports the keys matching no field, the keys setting fields encoding/json skips and,
+// when required is set, the non optional fields the body leaves out.
+func bodyFieldProblems(data map[string]any, structInfo StructInfo, structInfos map[string]StructInfo, required bool) []string {
+	c := fieldChecker{structInfos: structInfos, required: required}
+	c.checkStruct("", data, structInfo)
+	return c.problems
+}
+
+func (c *fieldChecker) report(path, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	c.problems = append(c.problems, msg)
+}
+
+func (c *fieldChecker) checkValue(path string, value any, typeInfo TypeInfo) {
+	// Expected responses, whose fields are not required, can match values instead of declaring them
+	if _, _, ok := bodyMatcher(value); ok && !c.required {
+		return
+	}
+
+	switch typeInfo.GoType {
+	case "pointer":
+		c.checkValue(path, value, *typeInfo.Elem)
+	case "struct":
+		data, ok := value.(map[string]any)
+		if structInfo, found := lookupStructInfo(typeInfo, c.structInfos); ok && found {
+			c.checkStruct(path, data, structInfo)
+		}
+	case "slice", "array":
+		items, _ := value.([]any)
+		for i, item := range items {
+			c.checkValue(fmt.Sprintf("%s[%d]", path, i), item, *typeInfo.Elem)
+		}
+	case "map":
+		entries, _ := value.(map[string]any)
+		for _, key := range slices.Sorted(maps.Keys(entries)) {
+			c.checkValue(joinKeyPath(path, key), entries[key], *typeInfo.Elem)
+		}
+	}
+}
+
+func (c *fieldChecker) checkStruct(path string, data map[string]any, structInfo StructInfo) {
+	matched := make(map[string]bool)
+	c.checkFields(path, data, structInfo, matched)
+
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		if matched[key] {
+			continue
+		}
+		if field, reason, ok := c.skippedField(key, structInfo); ok {
+			c.report(path, "key %q sets field %s, which encoding/json skips as it %s", key, field, reason)
+			continue
+		}
+		c.report(path, "key %q matches no field of %s", key, structInfo.Name)
+	}
+}
+
+// checkFields checks the fields of a struct, including promoted ones, recording the keys setting them
+func (c *fieldChecker) checkFields(path string, data map[string]any, structInfo StructInfo, matched map[string]bool) {
+	for _, field := range structInfo.Fields {
+		if isPromoted(field) {
+			if embeddedInfo, ok := lookupStructInfo(promotedStructType(field), c.structInfos); ok {
+				c.checkFields(path, data, embeddedInfo, matched)
+			}
+			continue
+		}
+
+		key, found := lookupJSONKey(data, field)
+		switch {
+		case found:
+			matched[key] = true
+			c.checkValue(joinKeyPath(path, key), data[key], field.TypeInfo)
+		case c.required && isEncoded(field) && !field.OmitEmpty && field.GoType != "pointer":
+			c.report(path, "missing required field %s (%q) of %s", field.Name, field.JSONTag, structInfo.Name)
+		}
+	}
+}
+
+// skippedField looks for a field encoding/json skips that a key would set by its name
+func (c *fieldChecker) skippedField(key string, structInfo StructInfo) (string, string, bool) {
+	for _, field := range structInfo.Fields {
+		if isPromoted(field) {
+			if embeddedInfo, ok := lookupStructInfo(promotedStructType(field), c.structInfos); ok {
+				if name, reason, found := c.skippedField(key, embeddedInfo); found {
+					return name, reason, true
+				}
+			}
+			continue
+		}
+
+		switch {
+		case isEncoded(field) || !strings.EqualFold(key, field.Name):
+			continue
+		case field.Ignored:
+			return field.Name, `is tagged with json:"-"`, true
+		default:
+			return field.Name, "is unexported", true
+		}
+	}
+	return "", "", false
+}
+
+// isEncoded reports whether encoding/json encodes and decodes a struct field
+func isEncoded(field StructField) bool {
+	return !field.Ignored && (field.Embedded || token.IsExported(field.Name))
+}
+
+// joinKeyPath appends a key to the path of a JSON value
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
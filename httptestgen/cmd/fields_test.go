@@ -0,0 +1,122 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+const accountsSrc = `package accounts
+
+type (
+	Audit struct {
+		CreatedBy string ` + "`json:\"created_by\"`" + `
+	}
+
+	Address struct {
+		City string ` + "`json:\"city\"`" + `
+		Zip  string ` + "`json:\"zip,omitempty\"`" + `
+	}
+
+	Account struct {
+		Audit
+		Email     string             ` + "`json:\"email\"`" + `
+		Nickname  *string            ` + "`json:\"nickname\"`" + `
+		Password  string             ` + "`json:\"-\"`" + `
+		Dash      string             ` + "`json:\"-,\"`" + `
+		Addresses []Address          ` + "`json:\"addresses,omitzero\"`" + `
+		ByName    map[string]Address ` + "`json:\"by_name,omitempty\"`" + `
+		secret    string
+	}
+
+	Contact struct {
+		EmailAddress string ` + "`json:\"email_address\"`" + `
+		Phone        string
+	}
+)
+`
+
+func TestBodyFieldProblems(t *testing.T) {
+	info, _, _, _ := loadTestPackage(t, accountsSrc)
+
+	for _, tt := range []struct {
+		name     string
+		body     string
+		required bool
+		expected []string
+	}{
+		{
+			name:     "it should accept bodies setting every required field",
+			body:     `{"email": "a@b.c", "created_by": "gopher", "-": "dash", "addresses": [{"city": "Rome"}]}`,
+			required: true,
+		},
+		{
+			name: "it should report keys matching no field",
+			body: `{"mail": "a@b.c", "addresses": [{"city": "Rome", "country": "IT"}], "by_name": {"home": {"town": "Rome"}}}`,
+			expected: []string{
+				`addresses[0]: key "country" matches no field of Address`,
+				`by_name.home: key "town" matches no field of Address`,
+				`key "mail" matches no field of Account`,
+			},
+		},
+		{
+			name: "it should not check the values of expected bodies matched by matchers",
+			body: `{"email": {"$regex": "@"}, "addresses": [{"$any": true}], "by_name": {"home": {"$type": "object"}}}`,
+		},
+		{
+			name: "it should report keys setting fields skipped by encoding/json",
+			body: `{"password": "hunter2", "secret": "s"}`,
+			expected: []string{
+				`key "password" sets field Password, which encoding/json skips as it is tagged with json:"-"`,
+				`key "secret" sets field secret, which encoding/json skips as it is unexported`,
+			},
+		},
+		{
+			name:     "it should report missing required fields, including promoted and nested ones",
+			body:     `{"addresses": [{"zip": "00100"}]}`,
+			required: true,
+			expected: []string{
+				`missing required field CreatedBy ("created_by") of Audit`,
+				`missing required field Email ("email") of Account`,
+				`missing required field Dash ("-") of Account`,
+				`addresses[0]: missing required field City ("city") of Address`,
+			},
+		},
+		{
+			name:     "it should not require fields when comparing bodies",
+			body:     `{"addresses": [{"zip": "00100"}]}`,
+			required: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := bodyFieldProblems(mustDecodeBody(t, tt.body), info.StructInfos["Account"], info.StructInfos, tt.required)
+			if !slices.Equal(tt.expected, problems) {
+				t.Fatalf("unexpected problems:\ngot:  %q\nwant: %q", problems, tt.expected)
+			}
+		})
+	}
+	for _, tt := range []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name: "it should match keys to json tags and untagged field names case-insensitively",
+			body: `{"EMAIL_ADDRESS": "a@b.c", "PHONE": "555"}`,
+		},
+		{
+			name: "it should not match keys to the names of tagged fields",
+			body: `{"emailaddress": "a@b.c", "phone": "555"}`,
+			expected: []string{
+				`missing required field EmailAddress ("email_address") of Contact`,
+				`key "emailaddress" matches no field of Contact`,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := bodyFieldProblems(mustDecodeBody(t, tt.body), info.StructInfos["Contact"], info.StructInfos, true)
+			if !slices.Equal(tt.expected, problems) {
+				t.Fatalf("unexpected problems:\ngot:  %q\nwant: %q", problems, tt.expected)
+			}
+		})
+	}
+}
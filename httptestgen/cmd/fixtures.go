@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// bodyFileKey is the key of the bodies read from a file, e.g. {"$file": "testdata/user.json"}
+const bodyFileKey = "$file"
+
+// bodyFileError is an error loading the body of a test case from a file.
+// Path locates the body in the spec.
+type bodyFileError struct {
+	Func      string
+	CaseDescr string
+	Path      []string
+	Err       error
+}
+
+func (e *bodyFileError) Error() string {
+	return fmt.Sprintf("%s: %q: %v", e.Func, e.CaseDescr, e.Err)
+}
+
+func (e *bodyFileError) Unwrap() error {
+	return e.Err
+}
+
+// loadBodyFiles replaces the request and response bodies declared as {"$file": path} by the content of the file,
+// a JSON or YAML object. Paths are relative to dir, the directory of the package under test.
+func loadBodyFiles(specs []FunctionTestSpec, dir string) error {
+	var errs []error
+	for i := range specs {
+		for j := range specs[i].RawCases {
+			testCase := &specs[i].RawCases[j]
+			casePath := []string{strconv.Itoa(i), "test-cases", strconv.Itoa(j)}
+
+			if err := loadBodyFile(&testCase.Request.Body, &testCase.Request.RawBody, dir); err != nil {
+				errs = append(errs, &bodyFileError{
+					Func:      specs[i].Func,
+					CaseDescr: testCase.CaseDescr,
+					Path:      append(casePath, "request", "body"),
+					Err:       fmt.Errorf("request body: %w", err),
+				})
+			}
+
+			// Response bodies are compared by their fields, the raw body is not needed
+			var rawBody string
+			if err := loadBodyFile(&testCase.Response.Body, &rawBody, dir); err != nil {
+				errs = append(errs, &bodyFileError{
+					Func:      specs[i].Func,
+					CaseDescr: testCase.CaseDescr,
+					Path:      append(casePath, "response", "body"),
+					Err:       fmt.Errorf("response body: %w", err),
+				})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadBodyFile reads a body declared as {"$file": path}, replacing it and its compacted JSON.
+// Other bodies are left as they are.
+func loadBodyFile(body *map[string]any, rawBody *string, dir string) error {
+	file, ok := bodyFile(*body)
+	if !ok {
+		return nil
+	}
+
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", file, err)
+	}
+
+	// YAML files are converted to JSON, as specs are
+	idx, err := indexSpec(file, data)
+	if err != nil {
+		return fmt.Errorf("could not parse %s", syntaxDiagnostic(file, data, err))
+	}
+
+	decoded, err := decodeBody(idx.json)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, idx.json); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	*body, *rawBody = decoded, compact.String()
+	return nil
+}
+
+// bodyFile returns the path of the file a body is read from, when it is declared as {"$file": path}
+func bodyFile(body map[string]any) (string, bool) {
+	if len(body) != 1 {
+		return "", false
+	}
+	file, ok := body[bodyFileKey].(string)
+	return file, ok
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadBodyFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "testdata"), 0o755); err != nil {
+		t.Fatalf("could not create testdata: %v", err)
+	}
+	for name, content := range map[string]string{
+		"testdata/user.json":     "{\n  \"id\": 9007199254740993,\n  \"name\": \"Andrea\"\n}\n",
+		"testdata/response.yaml": "user:\n  id: 1\nmessage: created\n",
+		"testdata/list.json":     "[1, 2]",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	decodeSpecs := func(t *testing.T, src string) []FunctionTestSpec {
+		t.Helper()
+		var specs []FunctionTestSpec
+		if err := json.Unmarshal([]byte(src), &specs); err != nil {
+			t.Fatalf("could not decode specs: %v", err)
+		}
+		return specs
+	}
+
+	t.Run("it should read request and response bodies from JSON and YAML files", func(t *testing.T) {
+		specs := decodeSpecs(t, `[{"func": "CreateUser", "test-cases": [{
+			"case_descr": "it should create users",
+			"request": {"body": {"$file": "testdata/user.json"}},
+			"response": {"status_code": 201, "body": {"$file": "testdata/response.yaml"}}
+		}]}]`)
+
+		if err := loadBodyFiles(specs, dir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		testCase := specs[0].RawCases[0]
+		if expected := `{"id":9007199254740993,"name":"Andrea"}`; testCase.Request.RawBody != expected {
+			t.Errorf("unexpected raw request body:\ngot:  %s\nwant: %s", testCase.Request.RawBody, expected)
+		}
+		if id := testCase.Request.Body["id"]; id != json.Number("9007199254740993") {
+			t.Errorf("expected numbers to be kept as written, got %#v", id)
+		}
+		if body, _ := json.Marshal(testCase.Response.Body); string(body) != `{"message":"created","user":{"id":1}}` {
+			t.Errorf("unexpected response body: %s", body)
+		}
+	})
+
+	t.Run("it should leave other bodies as they are", func(t *testing.T) {
+		specs := decodeSpecs(t, `[{"func": "CreateUser", "test-cases": [{
+			"case_descr": "it should create users",
+			"request": {"body": {"$file": "testdata/user.json", "name": "Andrea"}},
+			"response": {"status_code": 201}
+		}]}]`)
+
+		if err := loadBodyFiles(specs, dir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := specs[0].RawCases[0].Request.Body["$file"]; got != "testdata/user.json" {
+			t.Errorf("expected the body to be kept, got %v", specs[0].RawCases[0].Request.Body)
+		}
+	})
+
+	t.Run("it should report the bodies that cannot be read at their path", func(t *testing.T) {
+		specs := decodeSpecs(t, `[{"func": "CreateUser", "test-cases": [{
+			"case_descr": "it should create users",
+			"request": {"body": {"$file": "testdata/missing.json"}},
+			"response": {"status_code": 201, "body": {"$file": "testdata/list.json"}}
+		}]}]`)
+
+		err := loadBodyFiles(specs, dir)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		var paths [][]string
+		for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var fileErr *bodyFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("unexpected error type %T", err)
+			}
+			paths = append(paths, fileErr.Path)
+		}
+
+		expected := [][]string{{"0", "test-cases", "0", "request", "body"}, {"0", "test-cases", "0", "response", "body"}}
+		if !slices.EqualFunc(paths, expected, slices.Equal) {
+			t.Errorf("unexpected paths: got %v want %v", paths, expected)
+		}
+		for _, msg := range []string{`CreateUser: "it should create users": request body: could not read testdata/missing.json`, "response body: testdata/list.json: body must be an object"} {
+			if !strings.Contains(err.Error(), msg) {
+				t.Errorf("expected %q in error:\n%v", msg, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// standardImports maps the names of the standard library packages referenced by generated tests to their import paths
+var standardImports = map[string]string{
+	"bytes":    "bytes",
+	"context":  "context",
+	"errors":   "errors",
+	"filepath": "path/filepath",
+	"flag":     "flag",
+	"fmt":      "fmt",
+	"io":       "io",
+	"json":     "encoding/json",
+	"maps":     "maps",
+	"big":      "math/big",
+	"http":     "net/http",
+	"httptest": "net/http/httptest",
+	"os":       "os",
+	"regexp":   "regexp",
+	"slices":   "slices",
+	"strconv":  "strconv",
+	"strings":  "strings",
+	"testing":  "testing",
+	"time":     "time",
+}
+
+// formatTests adds the imports referenced by rendered tests and formats them.
+// Package names are resolved against the standard library first and then against pkgImports.
+func formatTests(src []byte, pkgImports map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, parseError(src, err)
+	}
+
+	paths := make(map[string]string)
+	for _, name := range unresolvedPackages(file) {
+		if importPath, ok := standardImports[name]; ok {
+			paths[name] = importPath
+		} else if importPath, ok := pkgImports[name]; ok {
+			paths[name] = importPath
+		}
+	}
+
+	// The imports are placed right after the package clause
+	offset := fset.Position(file.Name.End()).Offset
+	var out bytes.Buffer
+	out.Write(src[:offset])
+	out.WriteString("\n\n")
+	out.WriteString(importDecl(paths))
+	out.Write(src[offset:])
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, parseError(out.Bytes(), err)
+	}
+
+	return formatted, nil
+}
+
+// unresolvedPackages returns the sorted names of the identifiers used as package selectors
+// that are not declared in the file, which are the packages the file has to import
+func unresolvedPackages(file *ast.File) []string {
+	unresolved := make(map[*ast.Ident]struct{}, len(file.Unresolved))
+	for _, ident := range file.Unresolved {
+		unresolved[ident] = struct{}{}
+	}
+
+	names := make(map[string]struct{})
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				if _, ok := unresolved[ident]; ok {
+					names[ident.Name] = struct{}{}
+				}
+			}
+		}
+		return true
+	})
+
+	return slices.Sorted(maps.Keys(names))
+}
+
+// importDecl generates the import declaration of the given packages, keyed by name,
+// with standard library packages grouped before the others
+func importDecl(paths map[string]string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+
+	var std, others []string
+	for _, name := range slices.Sorted(maps.Keys(paths)) {
+		importPath := paths[name]
+		spec := strconv.Quote(importPath)
+		if path.Base(importPath) != name {
+			spec = name + " " + spec
+		}
+
+		if firstElem, _, _ := strings.Cut(importPath, "/"); strings.Contains(firstElem, ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+
+	slices.SortFunc(std, compareImportSpecs)
+	slices.SortFunc(others, compareImportSpecs)
+
+	groups := slices.DeleteFunc([]string{strings.Join(std, "\n"), strings.Join(others, "\n")}, func(group string) bool {
+		return group == ""
+	})
+	return fmt.Sprintf("import (\n%s\n)\n", strings.Join(groups, "\n\n"))
+}
+
+// compareImportSpecs orders import specs by path, as gofmt does
+func compareImportSpecs(a, b string) int {
+	return strings.Compare(a[strings.Index(a, `"`):], b[strings.Index(b, `"`):])
+}
+
+// testRunPattern matches the subtests rendered for test cases
+var testRunPattern = regexp.MustCompile(`^\s*t\.Run\(("(?:[^"\\]|\\.)*"), func\(t \*testing\.T\) \{$`)
+
+// parseError reports where rendered tests do not parse: the position in the rendered source,
+// the test function and case rendering it and the offending lines
+func parseError(src []byte, err error) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return fmt.Errorf("generated code does not parse: %w", err)
+	}
+
+	var (
+		pos                = list[0].Pos
+		lines              = strings.Split(string(src), "\n")
+		funcName, caseName = enclosingTest(lines, pos.Line)
+	)
+
+	region := "outside of test functions"
+	switch {
+	case funcName != "" && caseName != "":
+		region = fmt.Sprintf("in %s, case %q", funcName, caseName)
+	case funcName != "":
+		region = "in " + funcName
+	}
+
+	var snippet strings.Builder
+	for i := max(pos.Line-3, 0); i < min(pos.Line+2, len(lines)); i++ {
+		marker := "  "
+		if i == pos.Line-1 {
+			marker = "> "
+		}
+		fmt.Fprintf(&snippet, "\n%s%4d | %s", marker, i+1, lines[i])
+	}
+
+	return fmt.Errorf("generated code does not parse %s: %d:%d: %s%s", region, pos.Line, pos.Column, list[0].Msg, snippet.String())
+}
+
+// enclosingTest returns the names of the test function and subtest enclosing a line of generated tests,
+// walking back from the line to their declarations
+func enclosingTest(lines []string, line int) (funcName, caseName string) {
+	for i := min(line, len(lines)) - 1; i >= 0 && funcName == ""; i-- {
+		switch {
+		case strings.HasPrefix(lines[i], "func "):
+			funcName, _, _ = strings.Cut(strings.TrimPrefix(lines[i], "func "), "(")
+		case caseName == "":
+			if match := testRunPattern.FindStringSubmatch(lines[i]); match != nil {
+				caseName, _ = strconv.Unquote(match[1])
+			}
+		}
+	}
+	return funcName, caseName
+}
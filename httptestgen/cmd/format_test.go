@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRenderTests(t *testing.T) {
+	newSpec := func(testCase TestCase) GenerationSpec {
+		return GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{{
+				Func:      "PingHandler",
+				TestCases: []EnhancedTestCase{{TestCase: testCase}},
+			}},
+			Imports: map[string]string{"openapi": "example.com/openapi-go"},
+		}
+	}
+
+	t.Run("it should import only the packages referenced by the tests", func(t *testing.T) {
+		src, err := renderTests(newSpec(TestCase{
+			CaseDescr: "it should pong",
+			Response:  Response{StatusCode: 200},
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("could not parse generated tests: %v", err)
+		}
+
+		var imports []string
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			imports = append(imports, importPath)
+		}
+
+		if expected := []string{"context", "io", "net/http", "net/http/httptest", "testing", "time"}; !slices.Equal(expected, imports) {
+			t.Fatalf("unexpected imports:\ngot:  %v\nwant: %v", imports, expected)
+		}
+	})
+
+	t.Run("it should quote the names of subtests", func(t *testing.T) {
+		src, err := renderTests(newSpec(TestCase{
+			CaseDescr: `it should "pong" with a \ backslash`,
+			Response:  Response{StatusCode: 200},
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expected := `t.Run("it_should_\"pong\"_with_a_\\_backslash", func(t *testing.T) {`; !strings.Contains(string(src), expected) {
+			t.Fatalf("expected the tests to contain %s:\n%s", expected, src)
+		}
+	})
+
+	t.Run("it should report where the generated code does not parse", func(t *testing.T) {
+		spec := newSpec(TestCase{
+			CaseDescr: `it should "pong"`,
+			Response:  Response{StatusCode: 200, Body: map[string]any{"message": "pong"}},
+		})
+		spec.FunctionSpecs[0].TestCases[0].BodyMatch = "jsonMatch{"
+
+		_, err := renderTests(spec)
+		if err == nil {
+			t.Fatal("expected an error, got none")
+		}
+
+		if msg := err.Error(); !strings.Contains(msg, `in TestPingHandler, case "it_should_\"pong\""`) {
+			t.Fatalf("expected the error to point to the test case, got: %s", msg)
+		}
+	})
+}
+
+func TestRenderRoutedTests(t *testing.T) {
+	t.Run("it should serve requests through the router instead of calling the function", func(t *testing.T) {
+		var testCase TestCase
+		if err := json.Unmarshal([]byte(`{
+			"case_descr": "it should get users",
+			"request": {"path": "/users/{id}", "path_params": {"id": 1}},
+			"response": {"status_code": 200}
+		}`), &testCase); err != nil {
+			t.Fatalf("could not decode test case: %v", err)
+		}
+
+		src, err := renderTests(GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{{
+				Func:               "Users",
+				Router:             "NewRouter",
+				RouterReturnsError: true,
+				TestCases:          []EnhancedTestCase{{TestCase: testCase}},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, expected := range []string{"router, err := NewRouter()", "router.ServeHTTP(rr, req)", `"/users/1"`} {
+			if !strings.Contains(string(src), expected) {
+				t.Errorf("expected the tests to contain %q:\n%s", expected, src)
+			}
+		}
+		// The router extracts path values itself
+		for _, unexpected := range []string{"SetPathValue", "Users(rr, req)"} {
+			if strings.Contains(string(src), unexpected) {
+				t.Errorf("expected the tests not to contain %q:\n%s", unexpected, src)
+			}
+		}
+	})
+}
+
+func TestRenderGoldenTests(t *testing.T) {
+	t.Run("it should compare response bodies with their golden file", func(t *testing.T) {
+		var testCase TestCase
+		if err := json.Unmarshal([]byte(`{
+			"case_descr": "it should return ok",
+			"response": {"status_code": 200, "golden": "testdata/health.golden"}
+		}`), &testCase); err != nil {
+			t.Fatalf("could not decode test case: %v", err)
+		}
+
+		spec := GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{{
+				Func:      "HealthCheck",
+				TestCases: []EnhancedTestCase{{TestCase: testCase}},
+			}},
+		}
+		if !spec.UsesGolden() {
+			t.Fatal("expected the spec to use golden files")
+		}
+
+		src, err := renderTests(spec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, expected := range []string{
+			`assertGolden(t, "testdata/health.golden", rr.Body.Bytes())`,
+			`if flag.Lookup("update") == nil {`,
+			`json.Indent(&indented, bytes.TrimRight(body, " \t\r\n"), "", "  ")`,
+			`"path/filepath"`,
+		} {
+			if !strings.Contains(string(src), expected) {
+				t.Errorf("expected the tests to contain %q:\n%s", expected, src)
+			}
+		}
+	})
+}
+
+func TestImportDecl(t *testing.T) {
+	t.Run("it should group standard library imports and name imports not matching their path", func(t *testing.T) {
+		got := importDecl(map[string]string{
+			"testing": "testing",
+			"openapi": "example.com/openapi-go",
+			"json":    "encoding/json",
+		})
+
+		expected := "import (\n\"encoding/json\"\n\"testing\"\n\nopenapi \"example.com/openapi-go\"\n)\n"
+		if got != expected {
+			t.Fatalf("unexpected import declaration:\ngot:  %q\nwant: %q", got, expected)
+		}
+	})
+}
@@ -1,151 +1,435 @@
 package main
 
 import (
+	"bytes"
 	_ "embed"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"maps"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 )
 
+// addressOfHelper is the name of the generic helper returning the address of a value in generated tests
+const addressOfHelper = "addressOf"
+
 var (
 	//go:embed test.tpl
 	testTemplate string
 )
 
-// generateValueCode generates Go code for assigning a value to a field
-func generateValueCode(value any, goType, fullType string) string {
-	switch goType {
+// valueMismatchError is returned when a JSON value cannot be decoded into the Go type it is assigned to
+type valueMismatchError struct {
+	Value  string
+	Type   string
+	Reason string
+}
+
+func (e *valueMismatchError) Error() string {
+	return fmt.Sprintf("cannot use %s as %s: %s", e.Value, e.Type, e.Reason)
+}
+
+// mismatch returns a valueMismatchError for a value assigned to a type
+func mismatch(value any, typeInfo TypeInfo, reason string) error {
+	return &valueMismatchError{Value: jsonString(value), Type: typeInfo.Type, Reason: reason}
+}
+
+// generateValueCode generates Go code for assigning a value to a field of the given type.
+// JSON null leaves fields to their zero value, as encoding/json does.
+func generateValueCode(value any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	switch typeInfo.GoType {
 	case "string":
+		if value == nil {
+			return `""`, nil
+		}
 		str, ok := value.(string)
 		if !ok {
-			return `""`
+			return "", mismatch(value, typeInfo, "not a string")
 		}
-		return fmt.Sprintf(`"%s"`, str)
-	case "int":
-		switch v := value.(type) {
-		case float64:
-			return fmt.Sprintf("%d", int(v))
-		case int:
-			return fmt.Sprintf("%d", v)
-		case string:
-			// Try to parse string as int
-			return fmt.Sprintf(`%s`, v)
-		}
-		return "0"
-	case "uint":
-		switch v := value.(type) {
-		case float64:
-			return fmt.Sprintf("%d", uint(v))
-		case int:
-			return fmt.Sprintf("%d", uint(v))
-		}
-		return "0"
-	case "float":
-		switch v := value.(type) {
-		case float64:
-			return fmt.Sprintf("%f", v)
-		case int:
-			return fmt.Sprintf("%f", float64(v))
-		}
-		return "0.0"
+		return strconv.Quote(str), nil
+
+	case "int", "uint", "float":
+		return generateNumberCode(value, typeInfo)
 
 	case "bool":
-		if b, ok := value.(bool); ok {
-			return fmt.Sprintf("%t", b)
+		if value == nil {
+			return "false", nil
 		}
-		return "false"
+		b, ok := value.(bool)
+		if !ok {
+			return "", mismatch(value, typeInfo, "not a boolean")
+		}
+		return fmt.Sprintf("%t", b), nil
 
 	case "struct":
+		if value == nil {
+			return fmt.Sprintf("%s{}", typeInfo.Type), nil
+		}
 		// For nested structs, we'll generate struct literal syntax
-		if m, ok := value.(map[string]any); ok {
-			return generateStructLiteral(m, fullType)
+		m, ok := value.(map[string]any)
+		if !ok {
+			return "", mismatch(value, typeInfo, "not an object")
 		}
-		return fmt.Sprintf("%s{}", fullType)
+		return generateStructLiteral(m, typeInfo, structInfos)
+
+	case "pointer":
+		if value == nil {
+			return "nil", nil
+		}
+		code, err := generateValueCode(value, *typeInfo.Elem, structInfos)
+		if err != nil {
+			return "", err
+		}
+		// Composite literals are addressable, other values go through the addressOf helper
+		if typeInfo.Elem.GoType == "struct" {
+			return "&" + code, nil
+		}
+		return fmt.Sprintf("%s[%s](%s)", addressOfHelper, typeInfo.Elem.Type, code), nil
 
 	case "slice":
+		if value == nil {
+			return "nil", nil
+		}
+		if typeInfo.Elem != nil && typeInfo.Elem.Type == "byte" {
+			return generateBytesLiteral(value, typeInfo)
+		}
 		// For slices, generate slice literal
-		if arr, ok := value.([]any); ok {
-			return generateSliceLiteral(arr, fullType)
+		arr, ok := value.([]any)
+		if !ok {
+			return "", mismatch(value, typeInfo, "not an array")
 		}
-		return "nil"
+		return generateSliceLiteral(arr, typeInfo, structInfos)
 
-	default:
-		// Fallback to JSON marshaling for complex types
+	case "array":
+		if value == nil {
+			return fmt.Sprintf("%s{}", typeInfo.Type), nil
+		}
+		arr, ok := value.([]any)
+		if !ok {
+			return "", mismatch(value, typeInfo, "not an array")
+		}
+		return generateSliceLiteral(arr, typeInfo, structInfos)
+
+	case "map":
+		if value == nil {
+			return "nil", nil
+		}
+		m, ok := value.(map[string]any)
+		if !ok {
+			return "", mismatch(value, typeInfo, "not an object")
+		}
+		return generateMapLiteral(m, typeInfo, structInfos)
+
+	case "any":
+		return generateAnyLiteral(value)
+
+	case "raw":
 		if jsonBytes, err := json.Marshal(value); err == nil {
-			return fmt.Sprintf(`json.RawMessage(%s)`, "`"+string(jsonBytes)+"`")
+			return fmt.Sprintf("%s(%s)", typeInfo.Type, "`"+string(jsonBytes)+"`"), nil
 		}
-		return "nil"
+		return "nil", nil
+
+	default:
+		// Values of unsupported types (e.g. non-empty interfaces, funcs and channels) cannot be generated
+		return "nil", nil
 	}
 }
 
+// toJSONNumber returns a JSON number as written in the spec. Specs are decoded as json.Number,
+// other numeric types are accepted for values built in code.
+func toJSONNumber(value any) (json.Number, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return v, true
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), true
+	case int:
+		return json.Number(strconv.Itoa(v)), true
+	default:
+		return "", false
+	}
+}
+
+// generateNumberCode generates the exact literal of a JSON number assigned to an integer or float type,
+// failing when the number is not representable by the type
+func generateNumberCode(value any, typeInfo TypeInfo) (string, error) {
+	if value == nil {
+		return "0", nil
+	}
+
+	number, ok := toJSONNumber(value)
+	if !ok {
+		return "", mismatch(value, typeInfo, "not a number")
+	}
+
+	// Types described without a size default to 64 bits
+	if typeInfo.Bits == 0 {
+		typeInfo.Bits = 64
+	}
+
+	if typeInfo.GoType == "float" {
+		f, err := strconv.ParseFloat(number.String(), typeInfo.Bits)
+		if err != nil {
+			return "", mismatch(number, typeInfo, "out of range")
+		}
+		return strconv.FormatFloat(f, 'g', -1, typeInfo.Bits), nil
+	}
+
+	// Reject huge exponents before computing the exact value
+	if f, err := strconv.ParseFloat(number.String(), 64); err != nil || math.Abs(f) > math.MaxUint64 {
+		return "", mismatch(number, typeInfo, "out of range")
+	}
+
+	exact, ok := new(big.Rat).SetString(number.String())
+	if !ok {
+		return "", mismatch(number, typeInfo, "invalid number")
+	}
+	if !exact.IsInt() {
+		return "", mismatch(number, typeInfo, "not an integer")
+	}
+
+	minValue, maxValue := integerRange(typeInfo)
+	if n := exact.Num(); n.Cmp(minValue) < 0 || n.Cmp(maxValue) > 0 {
+		return "", mismatch(number, typeInfo, "out of range")
+	}
+
+	return exact.Num().String(), nil
+}
+
+// integerRange returns the minimum and maximum values of an integer type
+func integerRange(typeInfo TypeInfo) (*big.Int, *big.Int) {
+	one := big.NewInt(1)
+	if typeInfo.GoType == "uint" {
+		maxValue := new(big.Int).Lsh(one, uint(typeInfo.Bits))
+		return big.NewInt(0), maxValue.Sub(maxValue, one)
+	}
+
+	maxValue := new(big.Int).Lsh(one, uint(typeInfo.Bits-1))
+	minValue := new(big.Int).Neg(maxValue)
+	return minValue, maxValue.Sub(maxValue, one)
+}
+
+// jsonString returns the JSON representation of a value for error messages
+func jsonString(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
+
+// rawStringCode generates a string literal, as a raw string unless it holds backquotes or control characters
+func rawStringCode(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
 // generateStructLiteral generates Go struct literal code
-func generateStructLiteral(data map[string]any, typeName string) string {
+func generateStructLiteral(data map[string]any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	typeName := typeInfo.Type
 	if len(data) == 0 {
-		return fmt.Sprintf("%s{}", typeName)
+		return fmt.Sprintf("%s{}", typeName), nil
+	}
+
+	// Fields are named after the struct fields matching the JSON keys and follow their declaration order
+	structInfo, ok := lookupStructInfo(typeInfo, structInfos)
+	if !ok {
+		return fmt.Sprintf("%s{}", typeName), nil
+	}
+
+	assignments, err := generateFieldAssignments(data, structInfo, structInfos)
+	if err != nil {
+		return "", err
 	}
 
 	var fields []string
-	for key, value := range data {
-		// Convert key to Go field name (capitalize first letter)
-		fieldName := strings.Title(key)
-		valueCode := generateValueCode(value, inferGoTypeFromValue(value), "")
-		fields = append(fields, fmt.Sprintf("%s: %s", fieldName, valueCode))
+	for _, assignment := range assignments {
+		fields = append(fields, fmt.Sprintf("%s: %s", assignment.FieldName, assignment.ValueCode))
 	}
 
-	return fmt.Sprintf("%s{%s}", typeName, strings.Join(fields, ", "))
+	return fmt.Sprintf("%s{%s}", typeName, strings.Join(fields, ", ")), nil
 }
 
-// generateSliceLiteral generates Go slice literal code
-func generateSliceLiteral(data []any, typeName string) string {
-	if len(data) == 0 {
-		return "nil"
+// generateMapLiteral generates Go map literal code, with keys sorted for deterministic output
+func generateMapLiteral(data map[string]any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	var entries []string
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		keyCode, ok := generateMapKeyCode(key, *typeInfo.Key)
+		if !ok {
+			return "", mismatch(key, *typeInfo.Key, "invalid map key")
+		}
+		valueCode, err := generateValueCode(data[key], *typeInfo.Elem, structInfos)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		entries = append(entries, fmt.Sprintf("%s: %s", keyCode, valueCode))
+	}
+
+	return fmt.Sprintf("%s{%s}", typeInfo.Type, strings.Join(entries, ", ")), nil
+}
+
+// generateMapKeyCode generates Go code for a map key, JSON encodes integer keys as strings
+func generateMapKeyCode(key string, typeInfo TypeInfo) (string, bool) {
+	switch typeInfo.GoType {
+	case "string":
+		return strconv.Quote(key), true
+	case "int":
+		if _, err := strconv.ParseInt(key, 10, typeInfo.Bits); err == nil {
+			return key, true
+		}
+	case "uint":
+		if _, err := strconv.ParseUint(key, 10, typeInfo.Bits); err == nil {
+			return key, true
+		}
 	}
+	return "", false
+}
 
-	// Extract element type from slice type (e.g., "[]string" -> "string")
-	elementType := strings.TrimPrefix(typeName, "[]")
+// generateBytesLiteral generates Go code for a byte slice, which JSON encodes as a base64 string
+func generateBytesLiteral(value any, typeInfo TypeInfo) (string, error) {
+	str, ok := value.(string)
+	if !ok {
+		return "", mismatch(value, typeInfo, "not a base64 string")
+	}
 
-	var elements []string
-	for _, value := range data {
-		valueCode := generateValueCode(value, inferGoTypeFromValue(value), elementType)
-		elements = append(elements, valueCode)
+	decoded, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return "", mismatch(value, typeInfo, "not a base64 string")
 	}
 
-	return fmt.Sprintf("%s{%s}", typeName, strings.Join(elements, ", "))
+	return fmt.Sprintf("%s(%s)", typeInfo.Type, strconv.Quote(string(decoded))), nil
 }
 
-// inferGoTypeFromValue infers Go type from JSON value
-func inferGoTypeFromValue(value any) string {
-	switch value.(type) {
+// generateAnyLiteral generates Go code for a value assigned to an empty interface,
+// using the types encoding/json decodes JSON values into.
+func generateAnyLiteral(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "nil", nil
 	case string:
-		return "string"
-	case float64:
-		return "float"
+		return strconv.Quote(v), nil
+	case json.Number, float64, int:
+		code, err := generateNumberCode(v, TypeInfo{Type: "float64", GoType: "float", Bits: 64})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("float64(%s)", code), nil
 	case bool:
-		return "bool"
-	case map[string]any:
-		return "struct"
+		return fmt.Sprintf("%t", v), nil
 	case []any:
-		return "slice"
+		var elements []string
+		for i, elem := range v {
+			code, err := generateAnyLiteral(elem)
+			if err != nil {
+				return "", fmt.Errorf("[%d]: %w", i, err)
+			}
+			elements = append(elements, code)
+		}
+		return fmt.Sprintf("[]any{%s}", strings.Join(elements, ", ")), nil
+	case map[string]any:
+		var entries []string
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			code, err := generateAnyLiteral(v[key])
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", key, err)
+			}
+			entries = append(entries, fmt.Sprintf("%s: %s", strconv.Quote(key), code))
+		}
+		return fmt.Sprintf("map[string]any{%s}", strings.Join(entries, ", ")), nil
 	default:
-		return "interface"
+		return "nil", nil
 	}
 }
 
-// generateTests generates the test file
-func generateTests(spec GenerationSpec, outputFile string) error {
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return err
+// generateSliceLiteral generates Go slice and array literal code, with elements typed by the element type
+func generateSliceLiteral(data []any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	elements := make([]string, 0, len(data))
+	for i, value := range data {
+		code, err := generateValueCode(value, *typeInfo.Elem, structInfos)
+		if err != nil {
+			return "", fmt.Errorf("[%d]: %w", i, err)
+		}
+		elements = append(elements, code)
+	}
+
+	return fmt.Sprintf("%s{%s}", typeInfo.Type, strings.Join(elements, ", ")), nil
+}
+
+// UsesAddressOf reports whether any generated value needs the addressOf helper
+func (spec GenerationSpec) UsesAddressOf() bool {
+	for _, funcSpec := range spec.FunctionSpecs {
+		for _, testCase := range funcSpec.TestCases {
+			for _, field := range slices.Concat(testCase.RequestFields, testCase.ResponseFields) {
+				if strings.Contains(field.ValueCode, addressOfHelper+"[") {
+					return true
+				}
+			}
+			for _, mock := range testCase.MockSetups {
+				for _, call := range mock.Calls {
+					if strings.Contains(call, addressOfHelper+"[") {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// UsesGolden reports whether any test compares its response with a golden file, needing the assertGolden helper
+func (spec GenerationSpec) UsesGolden() bool {
+	for _, funcSpec := range spec.FunctionSpecs {
+		for _, testCase := range funcSpec.TestCases {
+			if testCase.Response.Golden != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UsesBodyMatch reports whether any test compares its response body with matchers, needing the matchJSON helper
+func (spec GenerationSpec) UsesBodyMatch() bool {
+	for _, funcSpec := range spec.FunctionSpecs {
+		for _, testCase := range funcSpec.TestCases {
+			if testCase.BodyMatch != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UsesAssertions reports whether any test checks assertions on its response body, needing the assertJSON helper
+func (spec GenerationSpec) UsesAssertions() bool {
+	for _, funcSpec := range spec.FunctionSpecs {
+		for _, testCase := range funcSpec.TestCases {
+			if len(testCase.Assertions) > 0 {
+				return true
+			}
+		}
 	}
-	defer file.Close()
+	return false
+}
 
+// renderTests renders the tests of a spec into formatted Go source importing the packages it references
+func renderTests(spec GenerationSpec) ([]byte, error) {
 	tmpl := template.Must(template.New("test").Funcs(template.FuncMap{
 		"jsonMarshal": func(v any) string {
 			b, _ := json.Marshal(v)
 			return string(b)
 		},
+		"quote":       strconv.Quote,
+		"rawString":   rawStringCode,
+		"stringSlice": stringSliceCode,
 		"hasBody": func(body map[string]any) bool {
 			return len(body) > 0
 		},
@@ -155,73 +439,226 @@ func generateTests(spec GenerationSpec, outputFile string) error {
 		"hasResponseFields": func(fields []FieldAssignment) bool {
 			return len(fields) > 0
 		},
-		"sanitizeName": func(s string) string {
-			// Convert description to a valid test name
-			s = strings.ReplaceAll(s, " ", "_")
-			s = strings.ReplaceAll(s, "-", "_")
-			s = strings.ReplaceAll(s, ".", "_")
-			return s
-		},
+		"sanitizeName": sanitizeName,
+		"testName":     testName,
 	}).Parse(testTemplate))
 
-	return tmpl.Execute(file, spec)
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, spec); err != nil {
+		return nil, fmt.Errorf("could not render tests: %w", err)
+	}
+
+	return formatTests(out.Bytes(), spec.Imports)
+}
+
+// sanitizeName converts a test case description to the name of its subtest
+func sanitizeName(s string) string {
+	s = strings.ReplaceAll(s, " ", "_")
+	s = strings.ReplaceAll(s, "-", "_")
+	s = strings.ReplaceAll(s, ".", "_")
+	return s
+}
+
+// lookupStructInfo returns the struct information of a struct type:
+// anonymous structs carry their fields, named structs are looked up by name.
+func lookupStructInfo(typeInfo TypeInfo, structInfos map[string]StructInfo) (StructInfo, bool) {
+	if typeInfo.Fields != nil {
+		return StructInfo{Name: typeInfo.Type, Fields: typeInfo.Fields}, true
+	}
+
+	structInfo, ok := structInfos[typeInfo.Type]
+	return structInfo, ok
 }
 
-func generateFieldAssignments(jsonData map[string]any, structInfo StructInfo) []FieldAssignment {
+// isPromoted reports whether the fields of an embedded struct are promoted to the enclosing struct in JSON
+func isPromoted(field StructField) bool {
+	return field.Embedded && field.JSONTag == ""
+}
+
+// promotedStructType returns the struct type of a promoted field, dereferencing pointers
+func promotedStructType(field StructField) TypeInfo {
+	if field.GoType == "pointer" {
+		return *field.Elem
+	}
+	return field.TypeInfo
+}
+
+// lookupJSONKey looks for the key of a JSON object setting a struct field. As encoding/json does, keys match the name
+// of the field in JSON, its json tag or else the field name, preferably exactly and otherwise case-insensitively.
+func lookupJSONKey(jsonData map[string]any, field StructField) (string, bool) {
+	// Promoted fields are looked up one by one, fields skipped by encoding/json are never set
+	if isPromoted(field) || !isEncoded(field) {
+		return "", false
+	}
+
+	// Fields without a json tag are named after their lowercased name, which matches the field name case-insensitively
+	if _, found := jsonData[field.JSONTag]; found {
+		return field.JSONTag, true
+	}
+	for _, key := range slices.Sorted(maps.Keys(jsonData)) {
+		if strings.EqualFold(key, field.JSONTag) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// lookupJSONValue looks for the JSON value matching a struct field
+func lookupJSONValue(jsonData map[string]any, field StructField) (any, bool) {
+	key, found := lookupJSONKey(jsonData, field)
+	return jsonData[key], found
+}
+
+// generateFieldAssignments generates the assignments of the struct fields set by a JSON object
+func generateFieldAssignments(jsonData map[string]any, structInfo StructInfo, structInfos map[string]StructInfo) ([]FieldAssignment, error) {
 	var assignments []FieldAssignment
 
 	for _, field := range structInfo.Fields {
-		// Look for matching JSON field (by json tag or field name)
-		var (
-			jsonValue any
-			found     bool
-		)
+		if isPromoted(field) {
+			// The embedded struct is set only if any of its promoted fields is
+			embeddedInfo, ok := lookupStructInfo(promotedStructType(field), structInfos)
+			if !ok {
+				continue
+			}
+			promoted, err := generateFieldAssignments(jsonData, embeddedInfo, structInfos)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name, err)
+			}
+			if len(promoted) == 0 {
+				continue
+			}
 
-		// Try JSON tag first
-		if jsonValue, found = jsonData[field.JSONTag]; !found {
-			// Try lowercase field name
-			if jsonValue, found = jsonData[strings.ToLower(field.Name)]; !found {
-				// Try exact field name
-				jsonValue, found = jsonData[field.Name]
+			valueCode, err := generateValueCode(jsonData, field.TypeInfo, structInfos)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name, err)
 			}
+
+			assignments = append(assignments, FieldAssignment{
+				FieldName: field.Name,
+				GoType:    field.GoType,
+				Value:     jsonData,
+				ValueCode: valueCode,
+			})
+			continue
 		}
 
+		jsonValue, found := lookupJSONValue(jsonData, field)
 		if !found {
 			continue
 		}
 
+		valueCode, err := generateValueCode(jsonValue, field.TypeInfo, structInfos)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Name, err)
+		}
+
 		assignments = append(assignments, FieldAssignment{
 			FieldName: field.Name,
 			GoType:    field.GoType,
 			Value:     jsonValue,
-			ValueCode: generateValueCode(jsonValue, field.GoType, field.Type),
+			ValueCode: valueCode,
 		})
 	}
 
-	return assignments
+	return assignments, nil
 }
 
 func prepareSpecs(
-	pkgName string,
+	pkgInfo PackageInfo,
 	testSpecs []FunctionTestSpec,
 	reqTypes []string,
-	structInfos map[string]StructInfo,
-) GenerationSpec {
+	respTypes map[string]string,
+) (GenerationSpec, error) {
+	var (
+		structInfos = pkgInfo.StructInfos
+		handlers    = pkgInfo.Handlers
+		problems    []string
+		usesMocks   bool
+	)
+
 	// Enhance test cases with type information and field mappings
 	for i := range testSpecs {
+		if err := resolveHandler(&testSpecs[i], pkgInfo); err != nil {
+			return GenerationSpec{}, fmt.Errorf("%s: %w", testSpecs[i].Func, err)
+		}
+		if errs := checkMocks(testSpecs[i], pkgInfo.Imports); len(errs) > 0 {
+			return GenerationSpec{}, fmt.Errorf("%s: %q: %w", testSpecs[i].Func, testSpecs[i].RawCases[errs[0].Case].CaseDescr, errs[0])
+		}
+
 		testSpecs[i].TestCases = make([]EnhancedTestCase, len(testSpecs[i].RawCases))
 		for j, rawCase := range testSpecs[i].RawCases {
-			requestType := inferRequestType(rawCase, reqTypes)
+			if rawCase.Response.Golden != "" && len(rawCase.Response.Body) > 0 {
+				return GenerationSpec{}, fmt.Errorf("%s: %q: response body and golden file cannot be declared together", testSpecs[i].Func, rawCase.CaseDescr)
+			}
+
+			bodyMatch, err := bodyMatchCode(rawCase.Response)
+			if err != nil {
+				return GenerationSpec{}, fmt.Errorf("%s: %q: response body: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+			}
+
+			requestType, err := inferRequestType(testSpecs[i], rawCase, handlers[testSpecs[i].handlerName()], reqTypes, structInfos)
+			if err != nil {
+				return GenerationSpec{}, fmt.Errorf("%s: %q: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+			}
+
+			responseType, err := inferResponseType(testSpecs[i], rawCase, handlers[testSpecs[i].handlerName()], respTypes, structInfos)
+			if err != nil {
+				return GenerationSpec{}, fmt.Errorf("%s: %q: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+			}
 
 			enhanced := EnhancedTestCase{
-				TestCase:    rawCase,
-				RequestType: requestType,
+				TestCase:     rawCase,
+				RequestType:  requestType,
+				ResponseType: responseType,
+				BodyMatch:    bodyMatch,
+			}
+
+			if enhanced.Assertions, err = assertionCodes(rawCase.Response.Assertions); err != nil {
+				return GenerationSpec{}, fmt.Errorf("%s: %q: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+			}
+
+			if enhanced.MockSetups, err = prepareMocks(rawCase.Mocks, pkgInfo.Interfaces, structInfos, pkgInfo.Imports); err != nil {
+				return GenerationSpec{}, fmt.Errorf("%s: %q: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+			}
+			if len(rawCase.Mocks) > 0 {
+				usesMocks = true
 			}
 
 			// Generate field assignments for request
 			if requestType != "" && len(rawCase.Request.Body) > 0 {
-				if structInfo, ok := structInfos[requestType]; ok {
-					enhanced.RequestFields = generateFieldAssignments(rawCase.Request.Body, structInfo)
+				if rawCase.Request.Raw {
+					enhanced.RawRequest = true
+				} else if structInfo, ok := structInfos[requestType]; ok {
+					fields, err := generateFieldAssignments(rawCase.Request.Body, structInfo, structInfos)
+					var mismatchErr *valueMismatchError
+					switch {
+					case errors.As(err, &mismatchErr):
+						// Bodies not fitting the request type are sent as written, e.g. to test malformed input
+						enhanced.RawRequest = true
+					case err != nil:
+						return GenerationSpec{}, fmt.Errorf("%s: %q: request body: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+					default:
+						enhanced.RequestFields = fields
+						for _, problem := range bodyFieldProblems(rawCase.Request.Body, structInfo, structInfos, true) {
+							problems = append(problems, fmt.Sprintf("%s: %q: request body: %s", testSpecs[i].Func, rawCase.CaseDescr, problem))
+						}
+					}
+				}
+			}
+
+			// Generate field assignments for response
+			if responseType != "" {
+				if structInfo, ok := structInfos[responseType]; ok {
+					// Bodies holding matchers are compared as JSON, their fields cannot be assigned
+					if bodyMatch == "" {
+						if enhanced.ResponseFields, err = generateFieldAssignments(rawCase.Response.Body, structInfo, structInfos); err != nil {
+							return GenerationSpec{}, fmt.Errorf("%s: %q: response body: %w", testSpecs[i].Func, rawCase.CaseDescr, err)
+						}
+					}
+					// Responses are compared on the fields declared in the spec, so none is required
+					for _, problem := range bodyFieldProblems(rawCase.Response.Body, structInfo, structInfos, false) {
+						problems = append(problems, fmt.Sprintf("%s: %q: response body: %s", testSpecs[i].Func, rawCase.CaseDescr, problem))
+					}
 				}
 			}
 
@@ -229,10 +666,22 @@ func prepareSpecs(
 		}
 	}
 
+	// Mocks use the gomock package imported by the package under test, if any
+	imports := pkgInfo.Imports
+	if _, ok := imports["gomock"]; usesMocks && !ok {
+		imports = maps.Clone(imports)
+		if imports == nil {
+			imports = make(map[string]string)
+		}
+		imports["gomock"] = gomockImportPath
+	}
+
 	return GenerationSpec{
-		PackageName:   pkgName,
+		PackageName:   pkgInfo.Name,
 		FunctionSpecs: testSpecs,
 		RequestTypes:  reqTypes,
 		StructInfos:   structInfos,
-	}
+		Imports:       imports,
+		FieldProblems: problems,
+	}, nil
 }
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+const shapesSrc = `package shapes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+var _ url.URL
+
+type (
+	Color  string
+	ID     = int64
+	Labels map[string]string
+	Tree   map[string]Tree
+	List   []List
+
+	Base struct {
+		ID        int    ` + "`json:\"id\"`" + `
+		CreatedBy string ` + "`json:\"created_by\"`" + `
+	}
+
+	Address struct {
+		City string ` + "`json:\"city\"`" + `
+	}
+
+	Shapes struct {
+		Base
+		Name      *string         ` + "`json:\"name\"`" + `
+		Age       *int64          ` + "`json:\"age\"`" + `
+		Home      *Address        ` + "`json:\"home\"`" + `
+		Color     Color           ` + "`json:\"color\"`" + `
+		Favourite *Color          ` + "`json:\"favourite\"`" + `
+		Owner     ID              ` + "`json:\"owner\"`" + `
+		Scores    map[string]int  ` + "`json:\"scores\"`" + `
+		ByID      map[int]Address ` + "`json:\"by_id\"`" + `
+		Labels    Labels          ` + "`json:\"labels\"`" + `
+		Meta      struct {
+			Version int ` + "`json:\"version\"`" + `
+		} ` + "`json:\"meta\"`" + `
+		Extra any             ` + "`json:\"extra\"`" + `
+		Raw   json.RawMessage ` + "`json:\"raw\"`" + `
+		Data  []byte          ` + "`json:\"data\"`" + `
+	}
+
+	User struct {
+		ID   int      ` + "`json:\"id\"`" + `
+		Name string   ` + "`json:\"name\"`" + `
+		Tags []string ` + "`json:\"tags\"`" + `
+	}
+
+	Node struct {
+		Name     string  ` + "`json:\"name\"`" + `
+		Children []*Node ` + "`json:\"children\"`" + `
+	}
+
+	Numbers struct {
+		ID      int64   ` + "`json:\"id\"`" + `
+		Small   int8    ` + "`json:\"small\"`" + `
+		Count   uint16  ` + "`json:\"count\"`" + `
+		Ratio   float32 ` + "`json:\"ratio\"`" + `
+		Price   float64 ` + "`json:\"price\"`" + `
+		Balance *int64  ` + "`json:\"balance\"`" + `
+		Extra   any     ` + "`json:\"extra\"`" + `
+	}
+
+	Nested struct {
+		Users   []User        ` + "`json:\"users\"`" + `
+		Grid    [2][2]int     ` + "`json:\"grid\"`" + `
+		Tree    Node          ` + "`json:\"tree\"`" + `
+		Request *http.Request ` + "`json:\"request\"`" + `
+		Folders Tree          ` + "`json:\"folders\"`" + `
+		Lists   List          ` + "`json:\"lists\"`" + `
+	}
+)
+
+func UserHandler(w http.ResponseWriter, r *http.Request) {}
+
+func addressOf[T any](v T) *T {
+	return &v
+}
+`
+
+// loadTestPackage type checks a single file package and collects its types
+func loadTestPackage(t *testing.T, src string) (PackageInfo, *token.FileSet, *ast.File, *types.Package) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "src.go", src, 0)
+	if err != nil {
+		t.Fatalf("could not parse source: %v", err)
+	}
+
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check(file.Name.Name, fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("could not type check source: %v", err)
+	}
+
+	info := PackageInfo{Name: pkg.Name()}
+	newTypeCollector(&info, pkg).collectScope(pkg)
+
+	return info, fset, file, pkg
+}
+
+// mustDecodeBody decodes a JSON body the way test cases are loaded
+func mustDecodeBody(t *testing.T, body string) map[string]any {
+	t.Helper()
+
+	data, err := decodeBody([]byte(body))
+	if err != nil {
+		t.Fatalf("could not decode body: %v", err)
+	}
+	return data
+}
+
+// checkExpr type checks a Go expression in the scope of the last declaration of a file,
+// so that the imports of the file are resolved
+func checkExpr(fset *token.FileSet, pkg *types.Package, file *ast.File, expr string) error {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return err
+	}
+	return types.CheckExpr(fset, pkg, file.Decls[len(file.Decls)-1].End()-1, e, nil)
+}
+
+func TestGenerateStructLiteral(t *testing.T) {
+	info, fset, file, pkg := loadTestPackage(t, shapesSrc)
+	shapes := TypeInfo{Type: "Shapes", GoType: "struct"}
+
+	for _, tt := range []struct {
+		shape    string
+		body     string
+		expected string
+	}{
+		{
+			shape:    "embedded structs",
+			body:     `{"id": 1, "created_by": "gopher"}`,
+			expected: `Shapes{Base: Base{ID: 1, CreatedBy: "gopher"}}`,
+		},
+		{
+			shape:    "keys matching json tags case-insensitively",
+			body:     `{"ID": 1, "Created_By": "gopher"}`,
+			expected: `Shapes{Base: Base{ID: 1, CreatedBy: "gopher"}}`,
+		},
+		{
+			shape:    "pointers to builtin types",
+			body:     `{"name": "gopher", "age": 13}`,
+			expected: `Shapes{Name: addressOf[string]("gopher"), Age: addressOf[int64](13)}`,
+		},
+		{
+			shape:    "pointers to structs",
+			body:     `{"home": {"city": "Florence"}}`,
+			expected: `Shapes{Home: &Address{City: "Florence"}}`,
+		},
+		{
+			shape:    "null pointers",
+			body:     `{"name": null}`,
+			expected: `Shapes{Name: nil}`,
+		},
+		{
+			shape:    "named types",
+			body:     `{"color": "blue", "favourite": "green"}`,
+			expected: `Shapes{Color: "blue", Favourite: addressOf[Color]("green")}`,
+		},
+		{
+			shape:    "type aliases",
+			body:     `{"owner": 7}`,
+			expected: `Shapes{Owner: 7}`,
+		},
+		{
+			shape:    "maps",
+			body:     `{"scores": {"b": 2, "a": 1}, "by_id": {"1": {"city": "Rome"}}}`,
+			expected: `Shapes{Scores: map[string]int{"a": 1, "b": 2}, ByID: map[int]Address{1: Address{City: "Rome"}}}`,
+		},
+		{
+			shape:    "named maps",
+			body:     `{"labels": {"team": "go"}}`,
+			expected: `Shapes{Labels: Labels{"team": "go"}}`,
+		},
+		{
+			shape:    "anonymous structs",
+			body:     `{"meta": {"version": 2}}`,
+			expected: `Shapes{Meta: struct{Version int "json:\"version\""}{Version: 2}}`,
+		},
+		{
+			shape:    "empty interfaces",
+			body:     `{"extra": {"k": [1, "v", true, null]}}`,
+			expected: `Shapes{Extra: map[string]any{"k": []any{float64(1), "v", true, nil}}}`,
+		},
+		{
+			shape:    "raw messages",
+			body:     `{"raw": {"a": 1}}`,
+			expected: "Shapes{Raw: json.RawMessage(`{\"a\":1}`)}",
+		},
+		{
+			shape:    "byte slices",
+			body:     `{"data": "aGk="}`,
+			expected: `Shapes{Data: []byte("hi")}`,
+		},
+	} {
+		t.Run("it should generate compilable literals for "+tt.shape, func(t *testing.T) {
+			got, err := generateStructLiteral(mustDecodeBody(t, tt.body), shapes, info.StructInfos)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("unexpected literal:\ngot:  %s\nwant: %s", got, tt.expected)
+			}
+
+			if err := checkExpr(fset, pkg, file, got); err != nil {
+				t.Fatalf("generated literal does not compile: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateNestedLiterals(t *testing.T) {
+	info, fset, file, pkg := loadTestPackage(t, shapesSrc)
+	nested := TypeInfo{Type: "Nested", GoType: "struct"}
+
+	for _, tt := range []struct {
+		shape    string
+		body     string
+		expected string
+	}{
+		{
+			shape:    "slices of structs",
+			body:     `{"users": [{"tags": ["a", "b"], "name": "Andrea", "id": 1}, {"id": 2}]}`,
+			expected: `Nested{Users: []User{User{ID: 1, Name: "Andrea", Tags: []string{"a", "b"}}, User{ID: 2}}}`,
+		},
+		{
+			shape:    "empty and null slices",
+			body:     `{"users": [{"id": 1, "tags": []}, {"id": 2, "tags": null}]}`,
+			expected: `Nested{Users: []User{User{ID: 1, Tags: []string{}}, User{ID: 2, Tags: nil}}}`,
+		},
+		{
+			shape:    "arrays",
+			body:     `{"grid": [[1, 2], [3, 4]]}`,
+			expected: `Nested{Grid: [2][2]int{[2]int{1, 2}, [2]int{3, 4}}}`,
+		},
+		{
+			shape:    "recursive types",
+			body:     `{"tree": {"name": "root", "children": [{"name": "leaf", "children": null}]}}`,
+			expected: `Nested{Tree: Node{Name: "root", Children: []*Node{&Node{Name: "leaf", Children: nil}}}}`,
+		},
+		{
+			shape:    "recursive named maps and slices",
+			body:     `{"folders": {"src": {"cmd": {}}, "docs": null}, "lists": [[], [[]]]}`,
+			expected: `Nested{Folders: Tree{"docs": nil, "src": Tree{"cmd": Tree{}}}, Lists: List{List{}, List{List{}}}}`,
+		},
+		{
+			shape:    "structs declared in other packages",
+			body:     `{"request": {"method": "GET", "url": {"host": "go.dev", "path": "/doc"}}}`,
+			expected: `Nested{Request: &http.Request{Method: "GET", URL: &url.URL{Host: "go.dev", Path: "/doc"}}}`,
+		},
+	} {
+		t.Run("it should generate typed nested literals for "+tt.shape, func(t *testing.T) {
+			got, err := generateStructLiteral(mustDecodeBody(t, tt.body), nested, info.StructInfos)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("unexpected literal:\ngot:  %s\nwant: %s", got, tt.expected)
+			}
+
+			if err := checkExpr(fset, pkg, file, got); err != nil {
+				t.Fatalf("generated literal does not compile: %v", err)
+			}
+		})
+	}
+
+	t.Run("it should collect the packages declaring nested types", func(t *testing.T) {
+		if got := info.Imports["url"]; got != "net/url" {
+			t.Fatalf("expected url to be imported from net/url, got %q", got)
+		}
+	})
+
+	t.Run("it should generate the same literal on every run", func(t *testing.T) {
+		body := map[string]any{"users": []any{map[string]any{"name": "a", "id": float64(1), "tags": []any{"x"}}}}
+		expected, err := generateStructLiteral(body, nested, info.StructInfos)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for range 20 {
+			if got, _ := generateStructLiteral(body, nested, info.StructInfos); got != expected {
+				t.Fatalf("non deterministic literal:\ngot:  %s\nwant: %s", got, expected)
+			}
+		}
+	})
+}
+
+func TestGenerateNumberLiterals(t *testing.T) {
+	info, fset, file, pkg := loadTestPackage(t, shapesSrc)
+	numbers := TypeInfo{Type: "Numbers", GoType: "struct"}
+
+	for _, tt := range []struct {
+		shape    string
+		body     string
+		expected string
+	}{
+		{
+			shape:    "integers not representable as float64",
+			body:     `{"id": 9007199254740993, "balance": -9223372036854775808}`,
+			expected: `Numbers{ID: 9007199254740993, Balance: addressOf[int64](-9223372036854775808)}`,
+		},
+		{
+			shape:    "integral values written with a fraction or an exponent",
+			body:     `{"small": -128, "count": 6.5535e4, "id": 1.0}`,
+			expected: `Numbers{ID: 1, Small: -128, Count: 65535}`,
+		},
+		{
+			shape:    "floats",
+			body:     `{"ratio": 0.1, "price": 1234567.891234567}`,
+			expected: `Numbers{Ratio: 0.1, Price: 1.234567891234567e+06}`,
+		},
+		{
+			shape:    "numbers assigned to empty interfaces",
+			body:     `{"extra": [1, 0.5]}`,
+			expected: `Numbers{Extra: []any{float64(1), float64(0.5)}}`,
+		},
+	} {
+		t.Run("it should generate exact literals for "+tt.shape, func(t *testing.T) {
+			got, err := generateStructLiteral(mustDecodeBody(t, tt.body), numbers, info.StructInfos)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("unexpected literal:\ngot:  %s\nwant: %s", got, tt.expected)
+			}
+
+			if err := checkExpr(fset, pkg, file, got); err != nil {
+				t.Fatalf("generated literal does not compile: %v", err)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{name: "when an integer overflows its field", body: `{"small": 128}`},
+		{name: "when a negative value is assigned to an unsigned field", body: `{"count": -1}`},
+		{name: "when a float overflows its field", body: `{"ratio": 1e39}`},
+		{name: "when an integer field receives a fraction", body: `{"id": 1.5}`},
+		{name: "when an integer field receives a string", body: `{"id": "1"}`},
+		{name: "when an integer field receives a huge exponent", body: `{"id": 1e1000000}`},
+	} {
+		t.Run("it should return an error "+tt.name, func(t *testing.T) {
+			if got, err := generateStructLiteral(mustDecodeBody(t, tt.body), numbers, info.StructInfos); err == nil {
+				t.Fatalf("expected an error, got %s", got)
+			}
+		})
+	}
+}
+
+func TestPrepareSpecsRawRequests(t *testing.T) {
+	info, _, _, pkg := loadTestPackage(t, shapesSrc)
+	info.Funcs = collectFuncs(pkg, nil)
+
+	prepare := func(t *testing.T, request, response string) (EnhancedTestCase, error) {
+		t.Helper()
+
+		var testCase TestCase
+		if err := json.Unmarshal([]byte(`{"request": `+request+`, "response": `+response+`}`), &testCase); err != nil {
+			t.Fatalf("could not unmarshal test case: %v", err)
+		}
+
+		spec, err := prepareSpecs(info, []FunctionTestSpec{{
+			Func:         "UserHandler",
+			RequestType:  "User",
+			ResponseType: "User",
+			RawCases:     []TestCase{testCase},
+		}}, nil, nil)
+		if err != nil {
+			return EnhancedTestCase{}, err
+		}
+		return spec.FunctionSpecs[0].TestCases[0], nil
+	}
+
+	t.Run("it should send request bodies not fitting the request type as written", func(t *testing.T) {
+		testCase, err := prepare(t, `{"body": {"name": 1, "id": 1.5}}`, `{"status_code": "400"}`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !testCase.RawRequest || testCase.RequestFields != nil {
+			t.Fatalf("expected a raw request, got %+v", testCase)
+		}
+	})
+
+	t.Run("it should generate typed requests for fitting bodies", func(t *testing.T) {
+		testCase, err := prepare(t, `{"body": {"name": "gopher", "tags": null}}`, `{"status_code": "200"}`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if testCase.RawRequest || len(testCase.RequestFields) != 2 {
+			t.Fatalf("expected a typed request, got %+v", testCase)
+		}
+	})
+
+	t.Run("it should send request bodies declared raw as written without checking their fields", func(t *testing.T) {
+		var testCase TestCase
+		if err := json.Unmarshal([]byte(`{"request": {"raw": true, "body": {"name": "gopher"}}, "response": {"status_code": "400"}}`), &testCase); err != nil {
+			t.Fatalf("could not unmarshal test case: %v", err)
+		}
+
+		spec, err := prepareSpecs(info, []FunctionTestSpec{{Func: "UserHandler", RequestType: "User", RawCases: []TestCase{testCase}}}, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := spec.FunctionSpecs[0].TestCases[0]; !got.RawRequest || got.RequestFields != nil {
+			t.Fatalf("expected a raw request, got %+v", got)
+		}
+		// The body leaves out the required id and tags fields
+		if len(spec.FieldProblems) > 0 {
+			t.Fatalf("expected no field problems, got %q", spec.FieldProblems)
+		}
+	})
+
+	t.Run("it should return an error when a response body does not fit the response type", func(t *testing.T) {
+		if _, err := prepare(t, `{}`, `{"status_code": "200", "body": {"tags": "a"}}`); err == nil {
+			t.Fatal("expected an error, got none")
+		}
+	})
+}
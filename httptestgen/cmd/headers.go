@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Headers represents HTTP headers declared in a spec.
+// Each header can be declared either as a single string or as a list of strings,
+// names are canonicalized so that "content-type" and "Content-Type" are the same header.
+type Headers map[string][]string
+
+// ExpectedHeaders represents HTTP headers that a response is expected to have.
+// Besides the single and multi value forms accepted by Headers,
+// a header can be declared as null to assert that the response does not have it.
+type ExpectedHeaders map[string][]string
+
+// UnmarshalJSON parses and validates headers declared in a spec
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	headers, err := parseHeaders(data, false)
+	if err != nil {
+		return err
+	}
+
+	*h = headers
+	return nil
+}
+
+// UnmarshalJSON parses and validates expected headers declared in a spec.
+// Absent headers are represented by a nil slice of values.
+func (h *ExpectedHeaders) UnmarshalJSON(data []byte) error {
+	headers, err := parseHeaders(data, true)
+	if err != nil {
+		return err
+	}
+
+	*h = headers
+	return nil
+}
+
+// parseHeaders parses a headers object, canonicalizing names and validating values
+func parseHeaders(data []byte, allowAbsent bool) (map[string][]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("headers must be an object: %w", err)
+	}
+
+	headers := make(map[string][]string, len(raw))
+	for name, rawValue := range raw {
+		if !isValidHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+
+		canonicalName := http.CanonicalHeaderKey(name)
+		if _, ok := headers[canonicalName]; ok {
+			return nil, fmt.Errorf("duplicate header %q", canonicalName)
+		}
+
+		if string(rawValue) == "null" {
+			if !allowAbsent {
+				return nil, fmt.Errorf("header %q must have a value", canonicalName)
+			}
+			headers[canonicalName] = nil
+			continue
+		}
+
+		values, err := parseHeaderValues(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for header %q: %w", canonicalName, err)
+		}
+
+		for _, value := range values {
+			if !isValidHeaderValue(value) {
+				return nil, fmt.Errorf("invalid value for header %q: %q", canonicalName, value)
+			}
+		}
+
+		headers[canonicalName] = values
+	}
+
+	return headers, nil
+}
+
+// parseHeaderValues accepts either a string or a non-empty list of strings
+func parseHeaderValues(data json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return nil, fmt.Errorf("expected a string or a list of strings")
+	}
+
+	if len(multi) == 0 {
+		return nil, fmt.Errorf("expected at least one value")
+	}
+
+	return multi, nil
+}
+
+// isValidHeaderName reports whether name is a valid RFC 7230 token
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValidHeaderValue reports whether value can be safely sent as a header value
+func isValidHeaderValue(value string) bool {
+	for _, r := range value {
+		if (r < ' ' && r != '\t') || r == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHeadersUnmarshalJSON(t *testing.T) {
+	t.Run("it should canonicalize names and accept single and multi values", func(t *testing.T) {
+		var headers Headers
+		if err := json.Unmarshal(
+			[]byte(`{"content-type": "application/json", "X-Forwarded-For": ["10.0.0.1", "10.0.0.2"]}`),
+			&headers,
+		); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := Headers{
+			"Content-Type":    {"application/json"},
+			"X-Forwarded-For": {"10.0.0.1", "10.0.0.2"},
+		}
+		if !reflect.DeepEqual(expected, headers) {
+			t.Fatalf("unexpected headers:\ngot:  %v\nwant: %v", headers, expected)
+		}
+	})
+
+	for _, tt := range []struct {
+		name string
+		data string
+	}{
+		{name: "when the same header is declared twice", data: `{"content-type": "a", "Content-Type": "b"}`},
+		{name: "when the header name is not a token", data: `{"Content Type": "a"}`},
+		{name: "when the header value contains a new line", data: `{"X-Test": "a\r\nX-Injected: b"}`},
+		{name: "when the header value is not a string", data: `{"X-Test": 1}`},
+		{name: "when the header has no values", data: `{"X-Test": []}`},
+	} {
+		t.Run("it should return an error "+tt.name, func(t *testing.T) {
+			var headers Headers
+			if err := json.Unmarshal([]byte(tt.data), &headers); err == nil {
+				t.Fatal("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestExpectedHeadersUnmarshalJSON(t *testing.T) {
+	t.Run("it should represent null headers as absent", func(t *testing.T) {
+		var headers ExpectedHeaders
+		if err := json.Unmarshal([]byte(`{"content-type": "application/json", "x-debug": null}`), &headers); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := ExpectedHeaders{
+			"Content-Type": {"application/json"},
+			"X-Debug":      nil,
+		}
+		if !reflect.DeepEqual(expected, headers) {
+			t.Fatalf("unexpected headers:\ngot:  %v\nwant: %v", headers, expected)
+		}
+	})
+
+	t.Run("it should return an error when a request header is null", func(t *testing.T) {
+		var headers Headers
+		if err := json.Unmarshal([]byte(`{"X-Debug": null}`), &headers); err == nil {
+			t.Fatal("expected an error, got none")
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// inferRequestType determines the appropriate request type for a test case.
+// Types declared on the test case take precedence over the ones declared on the function spec
+// and the one decoded by the handler, otherwise the request type whose fields best match the body is picked.
+func inferRequestType(
+	funcSpec FunctionTestSpec,
+	testCase TestCase,
+	handler HandlerInfo,
+	requestTypes []string,
+	structInfos map[string]StructInfo,
+) (string, error) {
+	if len(testCase.Request.Body) == 0 {
+		return "", nil
+	}
+
+	for _, t := range []string{testCase.RequestType, funcSpec.RequestType, handler.RequestType} {
+		if t == "" {
+			continue
+		}
+		if _, ok := structInfos[t]; !ok {
+			return "", fmt.Errorf("unsupported invalid request type: %s", t)
+		}
+		return t, nil
+	}
+
+	t, err := bestMatchingType(testCase.Request.Body, requestTypes, structInfos)
+	if err != nil {
+		return "", fmt.Errorf("could not infer request type: %w", err)
+	}
+
+	return t, nil
+}
+
+// inferResponseType determines the response type for a test case.
+// Types declared on the test case take precedence over the status codes mapped in the function spec,
+// then the default response type of the function spec, the type encoded by the handler for the status code,
+// the status codes passed via flags and the default response type passed via flags. When none of them apply,
+// the known response type whose fields best match the body is picked.
+func inferResponseType(
+	funcSpec FunctionTestSpec,
+	testCase TestCase,
+	handler HandlerInfo,
+	responseTypes map[string]string,
+	structInfos map[string]StructInfo,
+) (string, error) {
+	if len(testCase.Response.Body) == 0 {
+		return "", nil
+	}
+
+	statusCode := testCase.Response.StatusCode.String()
+	for _, t := range []string{
+		testCase.ResponseType,
+		funcSpec.ResponseTypes[statusCode],
+		funcSpec.ResponseType,
+		handler.ResponseTypes[statusCode],
+		responseTypes[statusCode],
+		responseTypes[""],
+	} {
+		if t == "" {
+			continue
+		}
+		if _, ok := structInfos[t]; !ok {
+			return "", fmt.Errorf("unsupported invalid response type: %s", t)
+		}
+		return t, nil
+	}
+
+	var candidates []string
+	for _, types := range []map[string]string{funcSpec.ResponseTypes, responseTypes} {
+		for _, t := range types {
+			if !slices.Contains(candidates, t) {
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	slices.Sort(candidates)
+
+	t, err := bestMatchingType(testCase.Response.Body, candidates, structInfos)
+	if err != nil {
+		return "", fmt.Errorf("could not infer response type: %w", err)
+	}
+
+	return t, nil
+}
+
+// bestMatchingType picks the candidate type whose fields best match the keys of a JSON body.
+// Candidates matching more keys win, ties are broken by the number of fields left unmatched.
+// No type is picked when none of the candidates match any key and, if there is still more than one
+// best candidate, an error is returned as the choice is ambiguous.
+func bestMatchingType(body map[string]any, candidates []string, structInfos map[string]StructInfo) (string, error) {
+	var (
+		best      []string
+		bestScore = typeMatchScore{matched: -1}
+	)
+	for _, candidate := range candidates {
+		structInfo, ok := structInfos[candidate]
+		if !ok {
+			return "", fmt.Errorf("unsupported invalid type: %s", candidate)
+		}
+
+		score := matchStruct(body, structInfo, structInfos)
+		switch {
+		case score.betterThan(bestScore):
+			best, bestScore = []string{candidate}, score
+		case score == bestScore:
+			best = append(best, candidate)
+		}
+	}
+
+	if bestScore.matched <= 0 {
+		return "", nil
+	}
+
+	if len(best) > 1 {
+		return "", fmt.Errorf("ambiguous types %s match the body keys equally, declare it explicitly", strings.Join(best, ", "))
+	}
+
+	return best[0], nil
+}
+
+// typeMatchScore describes how well a struct matches the keys of a JSON body
+type typeMatchScore struct {
+	matched   int
+	unmatched int
+}
+
+func (s typeMatchScore) betterThan(other typeMatchScore) bool {
+	if s.matched != other.matched {
+		return s.matched > other.matched
+	}
+	return s.unmatched < other.unmatched
+}
+
+// matchStruct scores how many fields of a struct, including promoted ones, are set by a JSON body
+func matchStruct(body map[string]any, structInfo StructInfo, structInfos map[string]StructInfo) typeMatchScore {
+	var score typeMatchScore
+	for _, field := range structInfo.Fields {
+		if isPromoted(field) {
+			if embeddedInfo, ok := lookupStructInfo(promotedStructType(field), structInfos); ok {
+				embeddedScore := matchStruct(body, embeddedInfo, structInfos)
+				score.matched += embeddedScore.matched
+				score.unmatched += embeddedScore.unmatched
+			}
+			continue
+		}
+		if !isEncoded(field) {
+			continue
+		}
+
+		if _, found := lookupJSONValue(body, field); found {
+			score.matched++
+			continue
+		}
+		score.unmatched++
+	}
+	return score
+}
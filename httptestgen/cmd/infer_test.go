@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestBestMatchingType(t *testing.T) {
+	structInfos := map[string]StructInfo{
+		"User": {Name: "User", Fields: []StructField{
+			{Name: "ID", JSONTag: "id"},
+			{Name: "Name", JSONTag: "name"},
+			{Name: "Email", JSONTag: "email"},
+		}},
+		"CreateUserRequest": {Name: "CreateUserRequest", Fields: []StructField{
+			{Name: "Name", JSONTag: "name"},
+			{Name: "Email", JSONTag: "email"},
+		}},
+		"UpdateUserRequest": {Name: "UpdateUserRequest", Fields: []StructField{
+			{Name: "Name", JSONTag: "name"},
+			{Name: "Email", JSONTag: "email"},
+		}},
+		"RenameUserRequest": {Name: "RenameUserRequest", Fields: []StructField{
+			{Name: "ID", JSONTag: "id"},
+			{Name: "Name", JSONTag: "name"},
+		}},
+	}
+
+	t.Run("it should pick the type matching most keys", func(t *testing.T) {
+		got, err := bestMatchingType(
+			map[string]any{"id": 1, "name": "Andrea", "email": "andrea@gitpod.io"},
+			[]string{"CreateUserRequest", "RenameUserRequest", "User"},
+			structInfos,
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "User" {
+			t.Fatalf("expected User, got %s", got)
+		}
+	})
+
+	t.Run("it should pick the type leaving fewer fields unmatched on ties", func(t *testing.T) {
+		got, err := bestMatchingType(
+			map[string]any{"name": "Andrea", "email": "andrea@gitpod.io"},
+			[]string{"User", "CreateUserRequest"},
+			structInfos,
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "CreateUserRequest" {
+			t.Fatalf("expected CreateUserRequest, got %s", got)
+		}
+	})
+
+	t.Run("it should not pick any type when no key matches", func(t *testing.T) {
+		got, err := bestMatchingType(map[string]any{"status": "ok"}, []string{"User"}, structInfos)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "" {
+			t.Fatalf("expected no type, got %s", got)
+		}
+	})
+
+	t.Run("it should return an error when the match is ambiguous", func(t *testing.T) {
+		if _, err := bestMatchingType(
+			map[string]any{"name": "Andrea", "email": "andrea@gitpod.io"},
+			[]string{"CreateUserRequest", "UpdateUserRequest"},
+			structInfos,
+		); err == nil {
+			t.Fatal("expected an error, got none")
+		}
+	})
+}
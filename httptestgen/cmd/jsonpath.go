@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSONPath parses a JSON path made of keys and indexes, such as $.items[0].id, into its segments.
+// Keys are returned as they are and indexes in brackets, e.g. ["items", "[0]", "id"];
+// * stands for any key and [*] for any index.
+func parseJSONPath(path string) ([]string, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid JSON path %q: must start with $", path)
+	}
+
+	var segments []string
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			key := rest[1:end]
+			if key == "" || strings.Contains(key, "]") {
+				return nil, fmt.Errorf("invalid JSON path %q: empty or invalid key after %q", path, path[:len(path)-len(rest)])
+			}
+			segments, rest = append(segments, key), rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSON path %q: unclosed [", path)
+			}
+			index := rest[1:end]
+			if n, err := strconv.Atoi(index); index != "*" && (err != nil || n < 0) {
+				return nil, fmt.Errorf("invalid JSON path %q: index %q is not a non negative integer or *", path, index)
+			}
+			segments, rest = append(segments, rest[:end+1]), rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q: expected . or [ after %q", path, path[:len(path)-len(rest)])
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	t.Run("it should split paths into keys and indexes", func(t *testing.T) {
+		for path, expected := range map[string][]string{
+			"$":                    nil,
+			"$.user.id":            {"user", "id"},
+			"$.items[0]":           {"items", "[0]"},
+			"$.items[*].tags[1]":   {"items", "[*]", "tags", "[1]"},
+			"$.*.created_at":       {"*", "created_at"},
+			"$[2].matrix[0][1].id": {"[2]", "matrix", "[0]", "[1]", "id"},
+		} {
+			segments, err := parseJSONPath(path)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", path, err)
+			}
+			if !slices.Equal(segments, expected) {
+				t.Errorf("%s: got %q want %q", path, segments, expected)
+			}
+		}
+	})
+
+	t.Run("it should reject invalid paths", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"user.id":     "must start with $",
+			"$.user.":     `empty or invalid key after "$.user"`,
+			"$.items[0":   "unclosed [",
+			"$.items[-1]": `index "-1" is not a non negative integer or *`,
+			"$.items[a]":  `index "a"`,
+			"$user":       `expected . or [ after "$"`,
+		} {
+			_, err := parseJSONPath(path)
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected an error containing %q, got %v", path, expected, err)
+			}
+		}
+	})
+}
@@ -4,44 +4,97 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 // TestCase represents a single test case
 type (
 	TestCase struct {
-		CaseDescr string   `json:"case_descr"`
-		Request   Request  `json:"request"`
-		Response  Response `json:"response"`
+		CaseDescr    string   `json:"case_descr"`
+		RequestType  string   `json:"request_type,omitempty"`
+		ResponseType string   `json:"response_type,omitempty"`
+		Request      Request  `json:"request"`
+		Response     Response `json:"response"`
+		// Mocks are created before the receiver of the test case is constructed
+		Mocks []Mock `json:"mocks,omitempty"`
 	}
 
 	Request struct {
-		Method string         `json:"method,omitempty"`
-		Path   string         `json:"path,omitempty"`
-		Body   map[string]any `json:"body,omitempty"`
+		Method string `json:"method,omitempty"`
+		// Path is the route template of the request, whose wildcards are filled with PathParams
+		Path       string         `json:"path,omitempty"`
+		PathParams PathParams     `json:"path_params,omitempty"`
+		Query      Query          `json:"query,omitempty"`
+		Body       map[string]any `json:"body,omitempty"`
+		Headers    Headers        `json:"headers,omitempty"`
+		// Raw sends the body as written without checking it against the request type,
+		// e.g. to test requests leaving out required fields
+		Raw bool `json:"raw,omitempty"`
+		// RawBody is the compacted body as written in the spec
+		RawBody string `json:"-"`
+		// URL is the URL built from the path and the parameters, PathValues the values of its wildcards
+		URL        string      `json:"-"`
+		PathValues []PathValue `json:"-"`
 	}
 
 	Response struct {
-		StatusCode string         `json:"status_code"`
-		Body       map[string]any `json:"body,omitempty"`
+		StatusCode StatusCode      `json:"status_code"`
+		Body       map[string]any  `json:"body,omitempty"`
+		Headers    ExpectedHeaders `json:"headers,omitempty"`
+		// Golden is the file holding the expected body, relative to the package directory,
+		// which the generated tests rewrite when run with -update
+		Golden string `json:"golden,omitempty"`
+		// Match relaxes the comparison of the body, which may also hold matchers, see bodyMatchCode
+		Match *BodyMatch `json:"match,omitempty"`
+		// Assertions check values at JSON paths of the body, whether or not it is compared as a whole
+		Assertions []Assertion `json:"assertions,omitempty"`
 	}
 
-	// EnhancedTestCase includes type information and field mappings
+	// EnhancedTestCase includes type information and field mappings.
+	// RequestType and ResponseType hold the resolved types, shadowing the ones declared in the spec.
 	EnhancedTestCase struct {
 		TestCase
 		RequestType    string
+		ResponseType   string
 		RequestFields  []FieldAssignment
 		ResponseFields []FieldAssignment
+		// RawRequest is set when the request body does not fit the request type,
+		// the body is then sent as written in the spec
+		RawRequest bool
+		MockSetups []MockSetup
+		// BodyMatch is the jsonMatch literal the response body is compared with,
+		// when it holds matchers or declares match options
+		BodyMatch string
+		// Assertions are the jsonAssertion literals checking the assertions of the response
+		Assertions []string
 	}
 
 	// FunctionTestSpec represents all test cases for a function
 	FunctionTestSpec struct {
-		Func      string             `json:"func"`
-		TestCases []EnhancedTestCase `json:"-"`
-		RawCases  []TestCase         `json:"test-cases"`
+		Func          string      `json:"func"`
+		RequestType   string      `json:"request_type,omitempty"`
+		ResponseType  string      `json:"response_type,omitempty"`
+		ResponseTypes StatusTypes `json:"response_types,omitempty"`
+		// Router is the function constructing the http.Handler serving the requests, instead of calling Func
+		Router string `json:"router,omitempty"`
+		// RouterReturnsError is set when Router returns an error along with the handler
+		RouterReturnsError bool `json:"-"`
+		// Constructor builds the receiver of the method, or of the type implementing http.Handler, under test:
+		// it is either a function of the package taking no arguments or a Go expression
+		Constructor string `json:"constructor,omitempty"`
+		// Receiver and Method are the type and the method called for methods and types implementing http.Handler,
+		// on the value of ReceiverExpr. ConstructorReturnsError is set when Constructor returns an error as well.
+		Receiver                string             `json:"-"`
+		Method                  string             `json:"-"`
+		ReceiverExpr            string             `json:"-"`
+		ConstructorReturnsError bool               `json:"-"`
+		TestCases               []EnhancedTestCase `json:"-"`
+		RawCases                []TestCase         `json:"test-cases"`
 	}
 
 	// FieldAssignment represents a Go struct field assignment
@@ -52,12 +105,34 @@ type (
 		ValueCode string
 	}
 
+	// TypeInfo describes a Go type for code generation
+	TypeInfo struct {
+		// Type is the type as written in Go code, e.g. "gopher.Color" or "map[string]int"
+		Type string
+		// GoType is the kind of the underlying type, e.g. "string" for "gopher.Color"
+		GoType string
+		// Bits is the size in bits of integer and float types
+		Bits int
+		// Elem is the element type of pointers, slices, arrays and maps
+		Elem *TypeInfo
+		// Key is the key type of maps
+		Key *TypeInfo
+		// Fields contains the fields of anonymous structs, named structs are described by their StructInfo
+		Fields []StructField
+	}
+
 	// StructField represents a field in a Go struct
 	StructField struct {
 		Name    string
-		Type    string
 		JSONTag string
-		GoType  string
+		// Embedded is set for embedded fields, whose fields are promoted
+		// to the enclosing struct in JSON when they have no json tag
+		Embedded bool
+		// OmitEmpty is set for fields tagged with omitempty or omitzero
+		OmitEmpty bool
+		// Ignored is set for fields tagged with "-", which are never encoded or decoded
+		Ignored bool
+		TypeInfo
 	}
 
 	// StructInfo contains information about a Go struct
@@ -72,6 +147,10 @@ type (
 		FunctionSpecs []FunctionTestSpec
 		RequestTypes  []string
 		StructInfos   map[string]StructInfo
+		// Imports maps the names of the packages generated code can reference to their import paths
+		Imports map[string]string
+		// FieldProblems lists the body keys and struct fields that do not match, see bodyFieldProblems
+		FieldProblems []string
 	}
 )
 
@@ -82,19 +161,28 @@ func main() {
 }
 
 func Main() error {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			return runValidate(os.Args[2:], os.Stdout)
+		case "convert":
+			return runConvert(os.Args[2:], os.Stdout)
+		}
+	}
+
 	cfg, err := initConfig()
 	if err != nil {
 		return fmt.Errorf("could not init config: %w", err)
 	}
 
-	// Parse the Go file to get package name and struct information
-	packageName, definedTypes, structInfos, err := parseGoFile(cfg.inputFile)
+	// Load the package to get package name, struct and handler information
+	pkgInfo, err := loadPackage(cfg.inputFile)
 	if err != nil {
-		return fmt.Errorf("could not parse input file %s: %w", cfg.inputFile, err)
+		return fmt.Errorf("could not load input %s: %w", cfg.inputFile, err)
 	}
 
-	var dtm = make(map[string]struct{}, len(definedTypes))
-	for _, dt := range definedTypes {
+	var dtm = make(map[string]struct{}, len(pkgInfo.DefinedTypes))
+	for _, dt := range pkgInfo.DefinedTypes {
 		dtm[dt] = struct{}{}
 	}
 
@@ -105,6 +193,13 @@ func Main() error {
 		}
 	}
 
+	// Check if passed response types are supported.
+	for _, t := range cfg.responseTypes {
+		if _, ok := pkgInfo.StructInfos[t]; !ok {
+			return fmt.Errorf("unsupported invalid response type: %s", t)
+		}
+	}
+
 	// Load test cases from JSON file.
 	testSpecs, err := loadTestCases(cfg.testCasesFile)
 	if err != nil {
@@ -115,14 +210,60 @@ func Main() error {
 		return fmt.Errorf("no test cases found in %s", cfg.testCasesFile)
 	}
 
+	// Bodies declared as {"$file": path} are read from files of the package under test.
+	if err := loadBodyFiles(testSpecs, pkgInfo.Dir); err != nil {
+		return fmt.Errorf("could not load body files:\n%w", err)
+	}
+
+	// Functions not declaring a router, nor a constructor, are served by the one passed via flags, if any.
+	for i := range testSpecs {
+		if testSpecs[i].Router == "" && testSpecs[i].Constructor == "" {
+			testSpecs[i].Router = cfg.router
+		}
+	}
+
 	// Prepare tests meta.
-	spec := prepareSpecs(packageName, testSpecs, cfg.requestTypes, structInfos)
+	spec, err := prepareSpecs(pkgInfo, testSpecs, cfg.requestTypes, cfg.responseTypes)
+	if err != nil {
+		return fmt.Errorf("could not prepare test cases: %w", err)
+	}
+
+	// Bodies not matching their types would silently leave fields out of the generated tests.
+	if len(spec.FieldProblems) > 0 {
+		if !cfg.lenient {
+			return fmt.Errorf("test case bodies do not match their types, fix them or pass -lenient:\n%s", strings.Join(spec.FieldProblems, "\n"))
+		}
+		for _, problem := range spec.FieldProblems {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", problem)
+		}
+	}
 
 	// Generate.
-	if err = generateTests(spec, cfg.outputFile); err != nil {
+	src, err := renderTests(spec)
+	if err != nil {
 		return fmt.Errorf("could not generate test cases: %w", err)
 	}
 
+	// Compare with the tests on disk, which were verified when written.
+	if cfg.check {
+		if err := checkTests(cfg.outputFile, src, os.Stdout); err != nil {
+			return err
+		}
+		fmt.Printf("%s is up to date\n", cfg.outputFile)
+		return nil
+	}
+
+	// Verify that the generated tests compile with the package under test.
+	if !cfg.noVerify {
+		if err := verifyTests(spec, cfg.outputFile, src); err != nil {
+			return fmt.Errorf("could not verify generated tests: %w", err)
+		}
+	}
+
+	if err = writeTests(cfg.outputFile, src, cfg.force); err != nil {
+		return fmt.Errorf("could not write generated tests: %w", err)
+	}
+
 	fmt.Printf("Generated tests for %d function(s) in %s\n", len(testSpecs), cfg.outputFile)
 	return nil
 }
@@ -134,26 +275,20 @@ func loadTestCases(filename string) ([]FunctionTestSpec, error) {
 		return nil, fmt.Errorf("failed to read test cases file: %w", err)
 	}
 
-	var specs []FunctionTestSpec
-	if err = json.Unmarshal(data, &specs); err != nil {
-		return nil, fmt.Errorf("failed to parse test cases JSON: %w", err)
+	// YAML specs are converted to JSON while being validated
+	diags, idx := validateSpec(filename, data)
+	if len(diags) > 0 {
+		return nil, fmt.Errorf("invalid test cases:\n%w", diagnosticsError(diags))
 	}
 
-	return specs, nil
-}
+	// Numbers are decoded as json.Number so that they are generated exactly as written
+	decoder := json.NewDecoder(bytes.NewReader(idx.json))
+	decoder.UseNumber()
 
-// inferRequestType determines the appropriate request type for a test case
-func inferRequestType(testCase TestCase, requestTypes []string) string {
-	if len(testCase.Request.Body) == 0 || len(requestTypes) == 0 {
-		return ""
-	}
-
-	// If there's only one request type, use it
-	if len(requestTypes) == 1 {
-		return requestTypes[0]
+	var specs []FunctionTestSpec
+	if err = decoder.Decode(&specs); err != nil {
+		return nil, fmt.Errorf("failed to parse test cases: %w", err)
 	}
 
-	// More sophisticated logic could be added here to match
-	// field names in the JSON to struct fields
-	return requestTypes[0]
+	return specs, nil
 }
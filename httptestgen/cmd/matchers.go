@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// bodyMatcherNames are the keys of the objects matching values of expected bodies instead of being compared with them
+	bodyMatcherNames = []string{"$any", "$regex", "$type", "$rfc3339"}
+
+	// jsonTypeNames are the JSON types $type matchers accept
+	jsonTypeNames = []string{"string", "number", "boolean", "object", "array", "null"}
+)
+
+// BodyMatch relaxes how a response body is compared with the expected one
+type BodyMatch struct {
+	// Subset is set when objects of the response may hold keys the expected body leaves out
+	Subset bool `json:"subset,omitempty"`
+	// Ignore lists the JSON paths whose values are not compared, e.g. $.user.id or $.items[*].created_at
+	Ignore []string `json:"ignore,omitempty"`
+	// Unordered lists the JSON paths of the arrays whose items may be in any order
+	Unordered []string `json:"unordered,omitempty"`
+}
+
+// bodyMatchCode checks the matchers and match options of an expected response. It returns the jsonMatch literal
+// generated tests compare the body with, or an empty string when the body is compared exactly.
+func bodyMatchCode(response Response) (string, error) {
+	usesMatchers, err := checkBodyMatchers("$", response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	match := response.Match
+	switch {
+	case match == nil && !usesMatchers:
+		return "", nil
+	case match == nil:
+		return "jsonMatch{}", nil
+	case len(response.Body) == 0:
+		return "", fmt.Errorf("match options need a response body")
+	}
+
+	for _, path := range slices.Concat(match.Ignore, match.Unordered) {
+		if _, err := parseJSONPath(path); err != nil {
+			return "", err
+		}
+	}
+
+	var fields []string
+	if match.Subset {
+		fields = append(fields, "Subset: true")
+	}
+	if len(match.Ignore) > 0 {
+		fields = append(fields, "Ignore: "+stringSliceCode(match.Ignore))
+	}
+	if len(match.Unordered) > 0 {
+		fields = append(fields, "Unordered: "+stringSliceCode(match.Unordered))
+	}
+	return fmt.Sprintf("jsonMatch{%s}", strings.Join(fields, ", ")), nil
+}
+
+// checkBodyMatchers checks the arguments of the matchers of an expected body, reporting whether it holds any
+func checkBodyMatchers(path string, value any) (bool, error) {
+	if name, arg, ok := bodyMatcher(value); ok {
+		switch name {
+		case "$regex":
+			expr, ok := arg.(string)
+			if !ok {
+				return false, fmt.Errorf("%s: $regex expects a regular expression, got %s", path, jsonString(arg))
+			}
+			if _, err := regexp.Compile(expr); err != nil {
+				return false, fmt.Errorf("%s: invalid regular expression %q: %w", path, expr, err)
+			}
+		case "$type":
+			if typeName, _ := arg.(string); !slices.Contains(jsonTypeNames, typeName) {
+				return false, fmt.Errorf("%s: $type expects one of %s, got %s", path, strings.Join(jsonTypeNames, ", "), jsonString(arg))
+			}
+		default:
+			if arg != true {
+				return false, fmt.Errorf("%s: %s expects true, got %s", path, name, jsonString(arg))
+			}
+		}
+		return true, nil
+	}
+
+	var found bool
+	switch v := value.(type) {
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			ok, err := checkBodyMatchers(path+"."+key, v[key])
+			if err != nil {
+				return false, err
+			}
+			found = found || ok
+		}
+	case []any:
+		for i, item := range v {
+			ok, err := checkBodyMatchers(path+"["+strconv.Itoa(i)+"]", item)
+			if err != nil {
+				return false, err
+			}
+			found = found || ok
+		}
+	}
+	return found, nil
+}
+
+// bodyMatcher returns the name and the argument of a matcher, an object with a single key among bodyMatcherNames
+func bodyMatcher(value any) (string, any, bool) {
+	obj, ok := value.(map[string]any)
+	if !ok || len(obj) != 1 {
+		return "", nil, false
+	}
+
+	for key, arg := range obj {
+		if slices.Contains(bodyMatcherNames, key) {
+			return key, arg, true
+		}
+	}
+	return "", nil, false
+}
+
+// stringSliceCode generates the code of a slice of strings
+func stringSliceCode(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+	return fmt.Sprintf("[]string{%s}", strings.Join(quoted, ", "))
+}
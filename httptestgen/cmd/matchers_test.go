@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBodyMatchCode(t *testing.T) {
+	decodeResponse := func(t *testing.T, src string) Response {
+		t.Helper()
+		var response Response
+		if err := json.Unmarshal([]byte(src), &response); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return response
+	}
+
+	t.Run("it should compare bodies without matchers nor options exactly", func(t *testing.T) {
+		code, err := bodyMatchCode(decodeResponse(t, `{"status_code": 200, "body": {"id": 1, "tags": [{"name": "$any"}]}}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if code != "" {
+			t.Errorf("expected no match code, got %s", code)
+		}
+	})
+
+	t.Run("it should match bodies holding matchers", func(t *testing.T) {
+		code, err := bodyMatchCode(decodeResponse(t, `{"status_code": 200, "body": {
+			"id": {"$type": "number"},
+			"items": [{"created_at": {"$rfc3339": true}}, {"$any": true}],
+			"email": {"$regex": "@example\\.com$"}
+		}}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if code != "jsonMatch{}" {
+			t.Errorf("unexpected match code: %s", code)
+		}
+	})
+
+	t.Run("it should generate the match options", func(t *testing.T) {
+		code, err := bodyMatchCode(decodeResponse(t, `{"status_code": 200, "body": {"name": "Andrea"}, "match": {
+			"subset": true, "ignore": ["$.id", "$.items[*].at"], "unordered": ["$.items"]
+		}}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := `jsonMatch{Subset: true, Ignore: []string{"$.id", "$.items[*].at"}, Unordered: []string{"$.items"}}`
+		if code != expected {
+			t.Errorf("unexpected match code:\ngot:  %s\nwant: %s", code, expected)
+		}
+	})
+
+	t.Run("it should reject invalid matchers and options", func(t *testing.T) {
+		for src, expected := range map[string]string{
+			`{"status_code": 200, "body": {"id": {"$type": "integer"}}}`:           "$.id: $type expects one of string, number, boolean, object, array, null, got \"integer\"",
+			`{"status_code": 200, "body": {"items": [{"$regex": "("}]}}`:           `$.items[0]: invalid regular expression "("`,
+			`{"status_code": 200, "body": {"at": {"$rfc3339": "yes"}}}`:            `$.at: $rfc3339 expects true, got "yes"`,
+			`{"status_code": 200, "body": {"id": 1}, "match": {"ignore": ["id"]}}`: `invalid JSON path "id"`,
+			`{"status_code": 200, "match": {"subset": true}}`:                      "match options need a response body",
+		} {
+			_, err := bodyMatchCode(decodeResponse(t, src))
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected an error containing %q, got %v", src, expected, err)
+			}
+		}
+	})
+}
+
+func TestRenderMatchedBodies(t *testing.T) {
+	t.Run("it should compare bodies with matchJSON", func(t *testing.T) {
+		var testCase TestCase
+		if err := json.Unmarshal([]byte(`{
+			"case_descr": "it should return ok",
+			"response": {"status_code": 200, "body": {"at": {"$rfc3339": true}}}
+		}`), &testCase); err != nil {
+			t.Fatalf("could not decode test case: %v", err)
+		}
+
+		src, err := renderTests(GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{{
+				Func:      "HealthCheck",
+				TestCases: []EnhancedTestCase{{TestCase: testCase, BodyMatch: "jsonMatch{Subset: true}"}},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, expected := range []string{
+			"problems, err := matchJSON(rr.Body.Bytes(), `{\"at\":{\"$rfc3339\":true}}`, jsonMatch{Subset: true})",
+			"func matchJSON(body []byte, expected string, match jsonMatch) ([]string, error) {",
+			`"regexp"`,
+		} {
+			if !strings.Contains(string(src), expected) {
+				t.Errorf("expected the tests to contain %q:\n%s", expected, src)
+			}
+		}
+	})
+}
+
+// runHelpers renders the helpers declared by generated tests using testCase, from the declaration starting with marker,
+// and runs them as a program whose main function is main
+func runHelpers(t *testing.T, testCase EnhancedTestCase, marker, main string) string {
+	t.Helper()
+
+	src, err := renderTests(GenerationSpec{
+		PackageName:   "main",
+		FunctionSpecs: []FunctionTestSpec{{Func: "Handler", TestCases: []EnhancedTestCase{testCase}}},
+	})
+	if err != nil {
+		t.Fatalf("could not render tests: %v", err)
+	}
+	helpers := src[bytes.Index(src, []byte(marker)):]
+
+	formatted, err := formatTests(fmt.Appendf(nil, "package main\n\n%s\n\n%s", main, helpers), nil)
+	if err != nil {
+		t.Fatalf("could not format program: %v", err)
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{"go.mod": []byte("module helpers\n\ngo 1.24\n"), "main.go": formatted} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not run helpers: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func TestMatchJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+
+	runMatchJSON := func(t *testing.T, body, expected, match string) string {
+		t.Helper()
+		return runHelpers(t, EnhancedTestCase{BodyMatch: "jsonMatch{}"}, "// jsonMatch relaxes", fmt.Sprintf(`func main() {
+	problems, err := matchJSON([]byte(%q), %q, %s)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(strings.Join(problems, "\n"))
+}`, body, expected, match))
+	}
+
+	for _, tt := range []struct {
+		name     string
+		body     string
+		expected string
+		match    string
+		problems string
+	}{
+		{
+			name:     "it should assign unordered items matched by matchers to distinct items",
+			body:     `{"ids":[1,2]}`,
+			expected: `{"ids":[{"$type":"number"},1]}`,
+			match:    `jsonMatch{Unordered: []string{"$.ids"}}`,
+		},
+		{
+			name:     "it should assign unordered items matched as subsets to distinct items",
+			body:     `{"users":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}`,
+			expected: `{"users":[{"name":"a"},{"id":1}]}`,
+			match:    `jsonMatch{Subset: true, Unordered: []string{"$.users"}}`,
+			problems: `$.users: no item matches {"id":1}`,
+		},
+		{
+			name:     "it should assign unordered items with ignored values to distinct items",
+			body:     `{"items":[{"id":2,"at":"b"},{"id":1,"at":"a"}]}`,
+			expected: `{"items":[{"id":{"$any":true},"at":"x"},{"id":2,"at":"y"}]}`,
+			match:    `jsonMatch{Ignore: []string{"$.items[*].at"}, Unordered: []string{"$.items"}}`,
+		},
+		{
+			name:     "it should report the expected items no item is left for",
+			body:     `{"ids":[1,1]}`,
+			expected: `{"ids":[1,2]}`,
+			match:    `jsonMatch{Unordered: []string{"$.ids"}}`,
+			problems: `$.ids: no item matches 2`,
+		},
+		{
+			name:     "it should compare integers beyond the precision of float64 exactly",
+			body:     `{"id":9007199254740993}`,
+			expected: `{"id":9007199254740992}`,
+			match:    `jsonMatch{Subset: true}`,
+			problems: `$.id: got 9007199254740993, want 9007199254740992`,
+		},
+		{
+			name:     "it should assign unordered integers beyond the precision of float64 exactly",
+			body:     `{"ids":[9007199254740993,9007199254740992]}`,
+			expected: `{"ids":[9007199254740992,9007199254740994]}`,
+			match:    `jsonMatch{Unordered: []string{"$.ids"}}`,
+			problems: `$.ids: no item matches 9007199254740994`,
+		},
+		{
+			name:     "it should compare numbers by value whatever their notation",
+			body:     `{"price":1.50,"quantity":1e2}`,
+			expected: `{"price":1.5,"quantity":100}`,
+			match:    `jsonMatch{}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runMatchJSON(t, tt.body, tt.expected, tt.match); got != tt.problems {
+				t.Errorf("unexpected problems:\ngot:  %s\nwant: %s", got, tt.problems)
+			}
+		})
+	}
+}
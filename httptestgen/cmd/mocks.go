@@ -0,0 +1,432 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// gomockImportPath is the import path of the package generated mock expectations use,
+// unless the package under test imports another package named gomock
+const gomockImportPath = "go.uber.org/mock/gomock"
+
+// reservedNames match the names declared by generated tests, which mocks cannot be named after
+var reservedNames = templateNames(testTemplate)
+
+// templateNamePart stands for the parts of the names the template builds from the spec, such as expected{{$field.FieldName}}JSON
+const templateNamePart = "\x00"
+
+var (
+	// localDeclRegexps match the variables and parameters declared by test functions,
+	// leaving out the ones scoped to if and for statements
+	localDeclRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s*((?:[\w\x00]+\s*,\s*)*[\w\x00]+)\s*:=`),
+		regexp.MustCompile(`(?m)^\s*var\s+((?:[\w\x00]+\s*,\s*)*[\w\x00]+)\s`),
+		regexp.MustCompile(`\bfunc\b[^(\n]*\(([\w\x00]+)\s`),
+	}
+	// topLevelDeclRegexps match the functions and types declared by helpers
+	topLevelDeclRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^(?:func|type|var|const)\s+([\w\x00]+)`),
+	}
+)
+
+// templateNames returns the expressions matching the names declared by the test template:
+// the variables of the test functions, ranging over the function specs, and the helpers declared along with them.
+// Names built from the spec, e.g. expected{{$field.FieldName}}JSON, match any name built alike.
+func templateNames(src string) []*regexp.Regexp {
+	tree := parse.New("test")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(src, "", "", make(map[string]*parse.Tree)); err != nil {
+		panic(fmt.Sprintf("could not parse the test template: %v", err))
+	}
+
+	var names []*regexp.Regexp
+	for _, node := range tree.Root.Nodes {
+		declRegexps := topLevelDeclRegexps
+		if _, ok := node.(*parse.RangeNode); ok {
+			declRegexps = localDeclRegexps
+		}
+
+		var text strings.Builder
+		writeTemplateText(&text, node)
+		var matches [][]string
+		for _, declRegexp := range declRegexps {
+			matches = append(matches, declRegexp.FindAllStringSubmatch(text.String(), -1)...)
+		}
+		for _, match := range matches {
+			for name := range strings.SplitSeq(match[1], ",") {
+				name = strings.TrimSpace(name)
+				// Names given by the spec alone are the ones of mocks, which are checked against each other
+				if name == "_" || strings.Trim(name, templateNamePart) == "" {
+					continue
+				}
+				parts := strings.Split(name, templateNamePart)
+				for i, part := range parts {
+					parts[i] = regexp.QuoteMeta(part)
+				}
+				names = append(names, regexp.MustCompile("^"+strings.Join(parts, ".+")+"$"))
+			}
+		}
+	}
+	return names
+}
+
+// writeTemplateText writes the text of a template node, the output of actions standing for templateNamePart
+// and the branches of control structures starting new lines
+func writeTemplateText(text *strings.Builder, node parse.Node) {
+	var branch *parse.BranchNode
+	switch node := node.(type) {
+	case *parse.ListNode:
+		for _, child := range node.Nodes {
+			writeTemplateText(text, child)
+		}
+		return
+	case *parse.TextNode:
+		text.Write(node.Text)
+		return
+	case *parse.ActionNode:
+		text.WriteString(templateNamePart)
+		return
+	case *parse.IfNode:
+		branch = &node.BranchNode
+	case *parse.RangeNode:
+		branch = &node.BranchNode
+	case *parse.WithNode:
+		branch = &node.BranchNode
+	default:
+		return
+	}
+
+	for _, list := range []*parse.ListNode{branch.List, branch.ElseList} {
+		if list != nil {
+			text.WriteString("\n")
+			writeTemplateText(text, list)
+		}
+	}
+	text.WriteString("\n")
+}
+
+// isReservedName reports whether a name is declared by generated tests
+func isReservedName(name string) bool {
+	return slices.ContainsFunc(reservedNames, func(re *regexp.Regexp) bool { return re.MatchString(name) })
+}
+
+type (
+	// Mock declares a gomock mock of an interface, available to the constructor of a test case by its name,
+	// and the calls it expects
+	Mock struct {
+		Name      string `json:"name"`
+		Interface string `json:"interface"`
+		// Constructor is the function creating the mock, NewMock followed by the interface name by default as mockgen does
+		Constructor string `json:"constructor,omitempty"`
+		// Ordered is set when the calls are expected in the order they are declared
+		Ordered bool       `json:"ordered,omitempty"`
+		Calls   []MockCall `json:"calls,omitempty"`
+	}
+
+	// MockCall is a call expected by a mock. Arguments are matched by equality, unless they are matchers:
+	// {"$any": true} matches any value, {"$nil": true} nil values and {"$go": "expr"} uses a Go expression as it is.
+	MockCall struct {
+		Method  string `json:"method"`
+		Args    []any  `json:"args,omitempty"`
+		Returns []any  `json:"returns,omitempty"`
+		// Times is the number of times the call is expected, once by default
+		Times *int `json:"times,omitempty"`
+	}
+
+	// MockSetup is the code creating a mock in generated tests, and recording the calls it expects
+	MockSetup struct {
+		Name        string
+		Constructor string
+		Ordered     bool
+		Calls       []string
+	}
+
+	// InterfaceInfo contains the methods of an interface
+	InterfaceInfo struct {
+		Name    string
+		Methods map[string]MethodInfo
+	}
+
+	// MethodInfo describes the parameters and results of a method
+	MethodInfo struct {
+		Params  []TypeInfo
+		Results []TypeInfo
+		// Variadic is set when the last parameter is variadic, its type being a slice
+		Variadic bool
+	}
+)
+
+// prepareMocks generates the code creating the mocks of a test case and recording the calls they expect.
+// Mocks cannot be named after the packages generated tests may import: the standard library packages they use,
+// gomock and the packages imported by the package under test, listed in pkgImports.
+func prepareMocks(mocks []Mock, interfaces map[string]InterfaceInfo, structInfos map[string]StructInfo, pkgImports map[string]string) ([]MockSetup, error) {
+	setups := make([]MockSetup, 0, len(mocks))
+	for i, mock := range mocks {
+		if msg := mockNameProblem(mocks[:i], mock.Name, pkgImports); msg != "" {
+			return nil, fmt.Errorf("mock %s: %s", mock.Name, msg)
+		}
+
+		iface, ok := interfaces[mock.Interface]
+		if !ok {
+			return nil, fmt.Errorf("mock %s: unknown interface %s", mock.Name, mock.Interface)
+		}
+
+		setup := MockSetup{
+			Name:        mock.Name,
+			Constructor: mock.Constructor,
+			Ordered:     mock.Ordered,
+		}
+		if setup.Constructor == "" {
+			_, name, _ := strings.Cut(mock.Interface, ".")
+			if name == "" {
+				name = mock.Interface
+			}
+			setup.Constructor = "NewMock" + name
+		}
+
+		for _, call := range mock.Calls {
+			code, err := mockCallCode(mock.Name, call, iface, structInfos)
+			if err != nil {
+				return nil, fmt.Errorf("mock %s: %s: %w", mock.Name, call.Method, err)
+			}
+			setup.Calls = append(setup.Calls, code)
+		}
+
+		setups = append(setups, setup)
+	}
+	return setups, nil
+}
+
+// mockNameProblem returns why a mock cannot be named name, if it cannot, declared is the mocks of the test case declared before
+func mockNameProblem(declared []Mock, name string, pkgImports map[string]string) string {
+	switch {
+	case isReservedName(name):
+		return "the name is used by the generated tests"
+	case standardImports[name] != "":
+		return "the name shadows package " + standardImports[name]
+	case pkgImports[name] != "":
+		return "the name shadows package " + pkgImports[name]
+	case name == "gomock":
+		return "the name shadows package " + gomockImportPath
+	case slices.ContainsFunc(declared, func(mock Mock) bool { return mock.Name == name }):
+		return "declared more than once"
+	}
+	return ""
+}
+
+// mockError is a problem of the mocks declared by a test case of a function spec.
+// Mock is the index of the offending mock, -1 when the problem concerns all the mocks of the test case.
+type mockError struct {
+	Case int
+	Mock int
+	Msg  string
+}
+
+func (e *mockError) Error() string {
+	return e.Msg
+}
+
+// checkMocks checks the names of the mocks declared by the test cases of a resolved function spec,
+// and that they reach the handler under test: mocks are only passed to it by a constructor expression referencing them.
+func checkMocks(spec FunctionTestSpec, pkgImports map[string]string) []*mockError {
+	var (
+		unusable   string
+		referenced = referencedNames(spec.ReceiverExpr)
+	)
+	switch {
+	case spec.Router != "":
+		unusable = fmt.Sprintf("%s is served by router %s, which takes no mocks, use a constructor expression passing them to the receiver", spec.Func, spec.Router)
+	case spec.Receiver == "":
+		unusable = fmt.Sprintf("%s is a function, which takes no mocks, test a method whose constructor expression passes them to the receiver", spec.Func)
+	case spec.ReceiverExpr != spec.Constructor:
+		unusable = fmt.Sprintf("constructor %s takes no mocks, use a constructor expression passing them to the receiver", spec.Constructor)
+	}
+
+	var errs []*mockError
+	for i, testCase := range spec.RawCases {
+		if len(testCase.Mocks) > 0 && unusable != "" {
+			errs = append(errs, &mockError{Case: i, Mock: -1, Msg: unusable})
+			continue
+		}
+		for j, mock := range testCase.Mocks {
+			msg := mockNameProblem(testCase.Mocks[:j], mock.Name, pkgImports)
+			if msg == "" && !referenced[mock.Name] {
+				msg = fmt.Sprintf("not used by constructor %s", spec.Constructor)
+			}
+			if msg != "" {
+				errs = append(errs, &mockError{Case: i, Mock: j, Msg: fmt.Sprintf("mock %s: %s", mock.Name, msg)})
+			}
+		}
+	}
+	return errs
+}
+
+// referencedNames returns the names an expression refers to, leaving out the fields and methods it selects
+func referencedNames(expr string) map[string]bool {
+	names := make(map[string]bool)
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return names
+	}
+
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(node.X, inspect)
+			return false
+		case *ast.Ident:
+			names[node.Name] = true
+		}
+		return true
+	}
+	ast.Inspect(parsed, inspect)
+	return names
+}
+
+// mockCallCode generates the code recording a call expected by a mock, e.g. store.EXPECT().Get(gomock.Any(), 1).Return(nil)
+func mockCallCode(name string, call MockCall, iface InterfaceInfo, structInfos map[string]StructInfo) (string, error) {
+	method, ok := iface.Methods[call.Method]
+	if !ok {
+		return "", fmt.Errorf("%s has no method %s", iface.Name, call.Method)
+	}
+
+	params := len(method.Params)
+	if len(call.Args) != params && (!method.Variadic || len(call.Args) < params-1) {
+		return "", fmt.Errorf("expected %d argument(s), got %d", params, len(call.Args))
+	}
+	if len(call.Returns) > 0 && len(call.Returns) != len(method.Results) {
+		return "", fmt.Errorf("expected %d return value(s), got %d", len(method.Results), len(call.Returns))
+	}
+
+	args := make([]string, 0, len(call.Args))
+	for i, arg := range call.Args {
+		// Variadic arguments are matched one by one against the element type of the last parameter
+		typeInfo := method.Params[min(i, params-1)]
+		if method.Variadic && i >= params-1 {
+			typeInfo = *typeInfo.Elem
+		}
+
+		code, err := mockArgCode(arg, typeInfo, structInfos)
+		if err != nil {
+			return "", fmt.Errorf("argument %d: %w", i, err)
+		}
+		args = append(args, code)
+	}
+
+	code := fmt.Sprintf("%s.EXPECT().%s(%s)", name, call.Method, strings.Join(args, ", "))
+
+	if len(call.Returns) > 0 {
+		returns := make([]string, 0, len(call.Returns))
+		for i, value := range call.Returns {
+			valueCode, err := mockReturnCode(value, method.Results[i], structInfos)
+			if err != nil {
+				return "", fmt.Errorf("return value %d: %w", i, err)
+			}
+			returns = append(returns, valueCode)
+		}
+		code += fmt.Sprintf(".Return(%s)", strings.Join(returns, ", "))
+	}
+
+	if call.Times != nil {
+		code += fmt.Sprintf(".Times(%d)", *call.Times)
+	}
+
+	return code, nil
+}
+
+// mockArgCode generates the code matching an argument of an expected call
+func mockArgCode(value any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	if matcher, matcherValue, ok := mockMatcher(value); ok {
+		switch matcher {
+		case "$any":
+			return "gomock.Any()", nil
+		case "$nil":
+			return "gomock.Nil()", nil
+		default:
+			return goExprCode(matcherValue)
+		}
+	}
+
+	// Nil values are matched whatever their type, gomock comparing untyped nils to typed ones
+	if value == nil && slices.Contains([]string{"pointer", "slice", "map", "any", "unsupported"}, typeInfo.GoType) {
+		return "gomock.Nil()", nil
+	}
+	return mockValueCode(value, typeInfo, structInfos)
+}
+
+// mockReturnCode generates the code of a value returned by an expected call
+func mockReturnCode(value any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	if matcher, matcherValue, ok := mockMatcher(value); ok {
+		if matcher != "$go" {
+			return "", fmt.Errorf("%s can only match arguments", matcher)
+		}
+		return goExprCode(matcherValue)
+	}
+	return mockValueCode(value, typeInfo, structInfos)
+}
+
+// mockValueCode generates the code of a value passed to, or returned by, a mock. Errors are written as their message.
+// Basic values are converted to their type, gomock comparing and returning values of the exact type of the method.
+func mockValueCode(value any, typeInfo TypeInfo, structInfos map[string]StructInfo) (string, error) {
+	switch {
+	case typeInfo.Type == "error":
+		switch v := value.(type) {
+		case nil:
+			return "nil", nil
+		case string:
+			return fmt.Sprintf("errors.New(%s)", strconv.Quote(v)), nil
+		default:
+			return "", mismatch(value, typeInfo, "not an error message")
+		}
+	case typeInfo.GoType == "unsupported" && value != nil:
+		return "", mismatch(value, typeInfo, `unsupported type, use {"$any": true} or {"$go": "expr"}`)
+	}
+
+	code, err := generateValueCode(value, typeInfo, structInfos)
+	if err != nil {
+		return "", err
+	}
+
+	switch typeInfo.GoType {
+	case "string", "int", "uint", "float", "bool":
+		// Untyped constants of their default type need no conversion
+		if !slices.Contains([]string{"string", "int", "float64", "bool"}, typeInfo.Type) {
+			return fmt.Sprintf("%s(%s)", typeInfo.Type, code), nil
+		}
+	}
+	return code, nil
+}
+
+// mockMatcher returns the name and the value of a matcher, an object with a single key among $any, $nil and $go
+func mockMatcher(value any) (string, any, bool) {
+	obj, ok := value.(map[string]any)
+	if !ok || len(obj) != 1 {
+		return "", nil, false
+	}
+
+	for key, matcherValue := range obj {
+		if slices.Contains([]string{"$any", "$nil", "$go"}, key) {
+			return key, matcherValue, true
+		}
+	}
+	return "", nil, false
+}
+
+// goExprCode returns the Go expression of a $go matcher, checking that it parses
+func goExprCode(value any) (string, error) {
+	expr, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("$go expects a Go expression, got %s", jsonString(value))
+	}
+	if _, err := parser.ParseExpr(expr); err != nil {
+		return "", fmt.Errorf("invalid Go expression %q: %w", expr, err)
+	}
+	return expr, nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const storeSrc = `package store
+
+import "context"
+
+type (
+	ID   int64
+	Item struct {
+		Name string ` + "`json:\"name\"`" + `
+	}
+
+	Store interface {
+		Get(ctx context.Context, id ID) (*Item, error)
+		List(ctx context.Context, names ...string) ([]Item, error)
+		Count(prefix *string) (*int, error)
+	}
+)
+`
+
+func TestPrepareMocks(t *testing.T) {
+	info, _, _, _ := loadTestPackage(t, storeSrc)
+
+	prepare := func(t *testing.T, mocks string) ([]MockSetup, error) {
+		t.Helper()
+
+		var decoded []Mock
+		if err := json.Unmarshal([]byte(mocks), &decoded); err != nil {
+			t.Fatalf("could not decode mocks: %v", err)
+		}
+		return prepareMocks(decoded, info.Interfaces, info.StructInfos, map[string]string{"cache": "example.com/cache"})
+	}
+
+	t.Run("it should record the expected calls with typed arguments and return values", func(t *testing.T) {
+		setups, err := prepare(t, `[{"name": "store", "interface": "Store", "ordered": true, "calls": [
+			{"method": "Get", "args": [{"$any": true}, 1], "returns": [{"name": "gopher"}, null]},
+			{"method": "Get", "args": [{"$go": "ctx"}, 2], "returns": [{"$go": "nil"}, "not found"]},
+			{"method": "List", "args": [{"$any": true}, "a", "b"], "returns": [null, null], "times": 2},
+			{"method": "List", "args": [{"$nil": true}]}
+		]}]`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			`store.EXPECT().Get(gomock.Any(), ID(1)).Return(&Item{Name: "gopher"}, nil)`,
+			`store.EXPECT().Get(ctx, ID(2)).Return(nil, errors.New("not found"))`,
+			`store.EXPECT().List(gomock.Any(), "a", "b").Return(nil, nil).Times(2)`,
+			`store.EXPECT().List(gomock.Nil())`,
+		}
+		if len(setups) != 1 || setups[0].Constructor != "NewMockStore" || !setups[0].Ordered || !slices.Equal(expected, setups[0].Calls) {
+			t.Fatalf("unexpected setups:\ngot:  %+v\nwant: %q", setups, expected)
+		}
+	})
+
+	t.Run("it should declare the addressOf helper for pointers to basic values", func(t *testing.T) {
+		setups, err := prepare(t, `[{"name": "store", "interface": "Store", "calls": [
+			{"method": "Count", "args": ["a"], "returns": [1, null]}
+		]}]`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := `store.EXPECT().Count(addressOf[string]("a")).Return(addressOf[int](1), nil)`
+		if got := setups[0].Calls[0]; got != expected {
+			t.Fatalf("unexpected call:\ngot:  %s\nwant: %s", got, expected)
+		}
+
+		spec := GenerationSpec{FunctionSpecs: []FunctionTestSpec{{TestCases: []EnhancedTestCase{{MockSetups: setups}}}}}
+		if !spec.UsesAddressOf() {
+			t.Fatal("expected the addressOf helper to be used")
+		}
+	})
+
+	for _, tt := range []struct {
+		name     string
+		mocks    string
+		expected string
+	}{
+		{
+			name:     "unknown interfaces",
+			mocks:    `[{"name": "store", "interface": "Cache"}]`,
+			expected: "mock store: unknown interface Cache",
+		},
+		{
+			name:     "names used by the generated tests",
+			mocks:    `[{"name": "req", "interface": "Store"}]`,
+			expected: "mock req: the name is used by the generated tests",
+		},
+		{
+			name:     "names built by the generated tests",
+			mocks:    `[{"name": "expectedAddressJSON", "interface": "Store"}]`,
+			expected: "mock expectedAddressJSON: the name is used by the generated tests",
+		},
+		{
+			name:     "names of the helpers declared by the generated tests",
+			mocks:    `[{"name": "matchJSON", "interface": "Store"}]`,
+			expected: "mock matchJSON: the name is used by the generated tests",
+		},
+		{
+			name:     "names of standard library packages used by the generated tests",
+			mocks:    `[{"name": "http", "interface": "Store"}]`,
+			expected: "mock http: the name shadows package net/http",
+		},
+		{
+			name:     "names of the gomock package",
+			mocks:    `[{"name": "gomock", "interface": "Store"}]`,
+			expected: "mock gomock: the name shadows package go.uber.org/mock/gomock",
+		},
+		{
+			name:     "names of packages imported by the package under test",
+			mocks:    `[{"name": "cache", "interface": "Store"}]`,
+			expected: "mock cache: the name shadows package example.com/cache",
+		},
+		{
+			name:     "unknown methods",
+			mocks:    `[{"name": "store", "interface": "Store", "calls": [{"method": "Delete"}]}]`,
+			expected: "mock store: Delete: Store has no method Delete",
+		},
+		{
+			name:     "missing arguments",
+			mocks:    `[{"name": "store", "interface": "Store", "calls": [{"method": "Get", "args": [1]}]}]`,
+			expected: "mock store: Get: expected 2 argument(s), got 1",
+		},
+		{
+			name:     "values of unsupported types",
+			mocks:    `[{"name": "store", "interface": "Store", "calls": [{"method": "Get", "args": ["ctx", 1]}]}]`,
+			expected: `mock store: Get: argument 0: cannot use "ctx" as context.Context: unsupported type, use {"$any": true} or {"$go": "expr"}`,
+		},
+		{
+			name:     "matchers returned by calls",
+			mocks:    `[{"name": "store", "interface": "Store", "calls": [{"method": "Get", "args": [{"$any": true}, 1], "returns": [{"$any": true}, null]}]}]`,
+			expected: "mock store: Get: return value 0: $any can only match arguments",
+		},
+	} {
+		t.Run("it should report "+tt.name, func(t *testing.T) {
+			_, err := prepare(t, tt.mocks)
+			if err == nil || err.Error() != tt.expected {
+				t.Fatalf("unexpected error:\ngot:  %v\nwant: %s", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+	t.Run("it should reserve the names declared by generated tests", func(t *testing.T) {
+		// The spec goes through every branch of the template declaring names
+		testCase := func(descr string) TestCase {
+			return TestCase{
+				CaseDescr: descr,
+				Request:   Request{Body: map[string]any{"name": "gopher"}},
+				Response: Response{
+					StatusCode: http.StatusOK,
+					Body:       map[string]any{"name": "gopher"},
+					Headers:    ExpectedHeaders{"Content-Type": {"application/json"}},
+					Golden:     "testdata/user.golden",
+				},
+			}
+		}
+		spec := GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{
+				{
+					Func:                    "GetUser",
+					Receiver:                "UserServer",
+					Method:                  "GetUser",
+					ReceiverExpr:            "NewUserServer(store)",
+					ConstructorReturnsError: true,
+					TestCases: []EnhancedTestCase{
+						{
+							TestCase:       testCase("it should compare fields"),
+							RequestType:    "UserRequest",
+							ResponseType:   "UserResponse",
+							RequestFields:  []FieldAssignment{{FieldName: "Name", GoType: "string", ValueCode: `"gopher"`}},
+							ResponseFields: []FieldAssignment{{FieldName: "Address", GoType: "struct", ValueCode: "Address{}"}},
+							MockSetups: []MockSetup{{
+								Name:        "store",
+								Constructor: "NewMockStore",
+								Calls:       []string{`store.EXPECT().Count(addressOf[string]("a"))`},
+							}},
+							Assertions: []string{"{Path: \"$.name\", Op: \"exists\", Value: `true`}"},
+						},
+						{TestCase: testCase("it should match the body"), BodyMatch: "jsonMatch{}"},
+						{TestCase: testCase("it should compare the body")},
+					},
+				},
+				{Func: "Routes", Router: "NewRouter", RouterReturnsError: true, TestCases: []EnhancedTestCase{{TestCase: testCase("it should route")}}},
+			},
+		}
+
+		src, err := renderTests(spec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+		if err != nil {
+			t.Fatalf("could not parse tests: %v", err)
+		}
+
+		// Names scoped to if and for statements cannot clash with mocks, declared at the top of test cases
+		declared := make(map[string]bool)
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil {
+					continue
+				}
+				if !strings.HasPrefix(decl.Name.Name, "Test") {
+					declared[decl.Name.Name] = true
+					continue
+				}
+				ast.Inspect(decl, func(node ast.Node) bool {
+					switch node := node.(type) {
+					case *ast.FuncType:
+						for _, param := range node.Params.List {
+							for _, name := range param.Names {
+								declared[name.Name] = true
+							}
+						}
+					case *ast.BlockStmt:
+						for _, stmt := range node.List {
+							if assign, ok := stmt.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+								for _, lhs := range assign.Lhs {
+									declared[lhs.(*ast.Ident).Name] = true
+								}
+							}
+							if decl, ok := stmt.(*ast.DeclStmt); ok {
+								for _, spec := range decl.Decl.(*ast.GenDecl).Specs {
+									for _, name := range spec.(*ast.ValueSpec).Names {
+										declared[name.Name] = true
+									}
+								}
+							}
+						}
+					}
+					return true
+				})
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						declared[spec.Name.Name] = true
+					}
+				}
+			}
+		}
+		delete(declared, "_")
+		delete(declared, "store")
+
+		for name := range declared {
+			if !isReservedName(name) {
+				t.Errorf("expected %s to be reserved", name)
+			}
+		}
+		names := slices.Collect(maps.Keys(declared))
+		for _, re := range reservedNames {
+			if !slices.ContainsFunc(names, re.MatchString) {
+				t.Errorf("expected %s to match a name declared by the tests", re)
+			}
+		}
+		for _, name := range []string{"expectedResponse", "expectedAddressJSON", "matchJSON", "t", "ctx"} {
+			if !declared[name] {
+				t.Errorf("expected %s to be declared by the tests", name)
+			}
+		}
+	})
+}
+
+func TestCheckMocks(t *testing.T) {
+	storeMocks := []TestCase{{CaseDescr: "it should get users", Mocks: []Mock{{Name: "store", Interface: "Store"}}}}
+
+	t.Run("it should accept mocks referenced by the constructor expression", func(t *testing.T) {
+		for _, constructor := range []string{"NewServer(store)", "&Server{store: store}", "NewServer(func() Store { return store }())"} {
+			spec := FunctionTestSpec{Func: "Server.GetUser", Constructor: constructor, Receiver: "Server", Method: "GetUser", ReceiverExpr: constructor, RawCases: storeMocks}
+			if errs := checkMocks(spec, nil); len(errs) > 0 {
+				t.Errorf("%s: unexpected errors: %v", constructor, errs)
+			}
+		}
+	})
+
+	for _, tt := range []struct {
+		name     string
+		spec     FunctionTestSpec
+		expected mockError
+	}{
+		{
+			name:     "mocks of handlers served by a router",
+			spec:     FunctionTestSpec{Func: "GetUser", Router: "NewRouter", RawCases: storeMocks},
+			expected: mockError{Case: 0, Mock: -1, Msg: "GetUser is served by router NewRouter, which takes no mocks, use a constructor expression passing them to the receiver"},
+		},
+		{
+			name:     "mocks of function handlers",
+			spec:     FunctionTestSpec{Func: "GetUserHandler", RawCases: storeMocks},
+			expected: mockError{Case: 0, Mock: -1, Msg: "GetUserHandler is a function, which takes no mocks, test a method whose constructor expression passes them to the receiver"},
+		},
+		{
+			name:     "mocks of receivers built by a constructor of the package",
+			spec:     FunctionTestSpec{Func: "Server.GetUser", Constructor: "NewServer", Receiver: "Server", Method: "GetUser", ReceiverExpr: "NewServer()", RawCases: storeMocks},
+			expected: mockError{Case: 0, Mock: -1, Msg: "constructor NewServer takes no mocks, use a constructor expression passing them to the receiver"},
+		},
+		{
+			name:     "mocks only selected by the constructor expression",
+			spec:     FunctionTestSpec{Func: "Server.GetUser", Constructor: "NewServer(deps.store)", Receiver: "Server", Method: "GetUser", ReceiverExpr: "NewServer(deps.store)", RawCases: storeMocks},
+			expected: mockError{Case: 0, Mock: 0, Msg: "mock store: not used by constructor NewServer(deps.store)"},
+		},
+	} {
+		t.Run("it should report "+tt.name, func(t *testing.T) {
+			errs := checkMocks(tt.spec, nil)
+			if len(errs) != 1 || *errs[0] != tt.expected {
+				t.Fatalf("unexpected errors:\ngot:  %+v\nwant: %+v", errs, tt.expected)
+			}
+		})
+	}
+}
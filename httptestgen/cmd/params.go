@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query represents the query parameters of a request declared in a spec.
+// Each parameter can be declared either as a single value or as a list of values,
+// values can be strings, numbers or booleans.
+type Query map[string][]string
+
+// PathParams represents the values of the wildcards of a request path declared in a spec,
+// such as {"id": 123} for "/users/{id}". Values can be strings, numbers or booleans.
+type PathParams map[string]string
+
+// PathValue is the value of a path wildcard, set on generated requests with SetPathValue
+type PathValue struct {
+	Name  string
+	Value string
+}
+
+// UnmarshalJSON parses and validates query parameters declared in a spec
+func (q *Query) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("query must be an object: %w", err)
+	}
+
+	query := make(Query, len(raw))
+	for name, rawValue := range raw {
+		values, err := parseParamValues(rawValue)
+		if err != nil {
+			return fmt.Errorf("invalid value for query parameter %q: %w", name, err)
+		}
+		query[name] = values
+	}
+
+	*q = query
+	return nil
+}
+
+// UnmarshalJSON parses and validates path parameters declared in a spec
+func (p *PathParams) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("path parameters must be an object: %w", err)
+	}
+
+	params := make(PathParams, len(raw))
+	for name, rawValue := range raw {
+		value, err := parseParamValue(rawValue)
+		if err != nil {
+			return fmt.Errorf("invalid value for path parameter %q: %w", name, err)
+		}
+		params[name] = value
+	}
+
+	*p = params
+	return nil
+}
+
+// parseParamValue accepts a string, a number, kept as written, or a boolean
+func parseParamValue(data json.RawMessage) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+	return "", errors.New("expected a string, a number or a boolean")
+}
+
+// parseParamValues accepts either a single value or a non-empty list of values
+func parseParamValues(data json.RawMessage) ([]string, error) {
+	if value, err := parseParamValue(data); err == nil {
+		return []string{value}, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.New("expected a value or a list of values")
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("expected at least one value")
+	}
+
+	values := make([]string, 0, len(raw))
+	for _, rawValue := range raw {
+		value, err := parseParamValue(rawValue)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// pathWildcardPattern matches the wildcards of ServeMux patterns, such as {id}, {path...} and {$}
+var pathWildcardPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// wildcardNamePattern matches the names ServeMux accepts for wildcards
+var wildcardNamePattern = regexp.MustCompile(`^[\pL_][\pL\pN_]*$`)
+
+// buildURL builds the URL of a request from a route template, filling its wildcards with the escaped
+// path parameters and adding the query parameters to its query. It returns the URL and the values
+// of the wildcards, in the order they appear in the template.
+func buildURL(template string, pathParams PathParams, query Query) (string, []PathValue, error) {
+	if template == "" {
+		template = "/"
+	}
+	path, rawQuery, _ := strings.Cut(template, "?")
+
+	var (
+		pathValues []PathValue
+		errs       []error
+	)
+	path = pathWildcardPattern.ReplaceAllStringFunc(path, func(wildcard string) string {
+		name, remainder := strings.CutSuffix(wildcard[1:len(wildcard)-1], "...")
+		switch {
+		case name == "$" && !remainder:
+			// {$} only anchors the pattern at the trailing slash
+			return ""
+		case !wildcardNamePattern.MatchString(name):
+			errs = append(errs, fmt.Errorf("invalid wildcard %s", wildcard))
+			return wildcard
+		case slices.ContainsFunc(pathValues, func(v PathValue) bool { return v.Name == name }):
+			errs = append(errs, fmt.Errorf("duplicate wildcard %s", wildcard))
+			return wildcard
+		}
+
+		value, ok := pathParams[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("missing path parameter %q for wildcard %s", name, wildcard))
+			return wildcard
+		}
+		pathValues = append(pathValues, PathValue{Name: name, Value: value})
+
+		// Wildcards ending with ... match the remainder of the path, including slashes
+		if remainder {
+			segments := strings.Split(value, "/")
+			for i, segment := range segments {
+				segments[i] = url.PathEscape(segment)
+			}
+			return strings.Join(segments, "/")
+		}
+		return url.PathEscape(value)
+	})
+
+	for _, name := range slices.Sorted(maps.Keys(pathParams)) {
+		if !slices.ContainsFunc(pathValues, func(v PathValue) bool { return v.Name == name }) {
+			errs = append(errs, fmt.Errorf("path parameter %q matches no wildcard of %s", name, template))
+		}
+	}
+	if len(errs) > 0 {
+		return "", nil, errors.Join(errs...)
+	}
+
+	if len(query) > 0 {
+		values, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid query in %s: %w", template, err)
+		}
+		for name, params := range query {
+			for _, param := range params {
+				values.Add(name, param)
+			}
+		}
+		rawQuery = values.Encode()
+	}
+
+	if rawQuery != "" {
+		path += "?" + rawQuery
+	}
+	return path, pathValues, nil
+}
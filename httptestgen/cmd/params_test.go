@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParamsUnmarshalJSON(t *testing.T) {
+	t.Run("it should accept single and multiple values of any scalar type", func(t *testing.T) {
+		var query Query
+		if err := json.Unmarshal([]byte(`{"q": "go", "page": 2, "exact": true, "tag": ["a", 1.50]}`), &query); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := Query{"q": {"go"}, "page": {"2"}, "exact": {"true"}, "tag": {"a", "1.50"}}
+		if !reflect.DeepEqual(expected, query) {
+			t.Fatalf("unexpected query:\ngot:  %v\nwant: %v", query, expected)
+		}
+	})
+
+	for _, tt := range []struct {
+		name   string
+		data   string
+		target any
+	}{
+		{name: "it should reject empty lists of values", data: `{"tag": []}`, target: &Query{}},
+		{name: "it should reject objects as query values", data: `{"filter": {"a": 1}}`, target: &Query{}},
+		{name: "it should reject lists as path parameters", data: `{"id": [1, 2]}`, target: &PathParams{}},
+		{name: "it should reject null path parameters", data: `{"id": null}`, target: &PathParams{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := json.Unmarshal([]byte(tt.data), tt.target); err == nil {
+				t.Fatal("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestBuildURL(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		template   string
+		pathParams PathParams
+		query      Query
+		url        string
+		pathValues []PathValue
+	}{
+		{
+			name:     "it should default to the root path",
+			template: "",
+			url:      "/",
+		},
+		{
+			name:       "it should fill wildcards in order, escaping their values",
+			template:   "/orgs/{org}/users/{id}",
+			pathParams: PathParams{"id": "a b/c", "org": "gophers"},
+			url:        "/orgs/gophers/users/a%20b%2Fc",
+			pathValues: []PathValue{{Name: "org", Value: "gophers"}, {Name: "id", Value: "a b/c"}},
+		},
+		{
+			name:       "it should keep the slashes of wildcards matching the remainder of the path",
+			template:   "/files/{path...}",
+			pathParams: PathParams{"path": "docs/read me.md"},
+			url:        "/files/docs/read%20me.md",
+			pathValues: []PathValue{{Name: "path", Value: "docs/read me.md"}},
+		},
+		{
+			name:     "it should drop the {$} anchor",
+			template: "/users/{$}",
+			url:      "/users/",
+		},
+		{
+			name:     "it should add the query parameters to the query of the path",
+			template: "/search?lang=en",
+			query:    Query{"q": {"go & gophers"}, "tag": {"b", "a"}},
+			url:      "/search?lang=en&q=go+%26+gophers&tag=b&tag=a",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			url, pathValues, err := buildURL(tt.template, tt.pathParams, tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if url != tt.url {
+				t.Errorf("unexpected url: got %s want %s", url, tt.url)
+			}
+			if !reflect.DeepEqual(tt.pathValues, pathValues) {
+				t.Errorf("unexpected path values:\ngot:  %v\nwant: %v", pathValues, tt.pathValues)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name       string
+		template   string
+		pathParams PathParams
+		expected   string
+	}{
+		{
+			name:     "it should report wildcards without path parameters",
+			template: "/users/{id}",
+			expected: `missing path parameter "id" for wildcard {id}`,
+		},
+		{
+			name:       "it should report path parameters matching no wildcard",
+			template:   "/users/{id}",
+			pathParams: PathParams{"id": "1", "org": "gophers"},
+			expected:   `path parameter "org" matches no wildcard of /users/{id}`,
+		},
+		{
+			name:       "it should report invalid and duplicate wildcards",
+			template:   "/users/{id}/{id}/{1st}",
+			pathParams: PathParams{"id": "1"},
+			expected:   "duplicate wildcard {id}\ninvalid wildcard {1st}",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := buildURL(tt.template, tt.pathParams, nil)
+			if err == nil || err.Error() != tt.expected {
+				t.Fatalf("unexpected error:\ngot:  %v\nwant: %s", err, tt.expected)
+			}
+		})
+	}
+}
@@ -1,149 +1,515 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
+
+	"golang.org/x/tools/go/packages"
 )
 
-// parseGoFile extracts package name, defined types, and struct information from a Go file
-func parseGoFile(filename string) (string, []string, map[string]StructInfo, error) {
-	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ParseComments)
+// loadMode loads the package under test with its syntax and type information.
+// Dependencies are type checked from source so that loading does not depend on
+// the export data format of the installed toolchain.
+const loadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedTypes |
+	packages.NeedSyntax |
+	packages.NeedTypesInfo
+
+// PackageInfo contains the information discovered in the package under test
+type PackageInfo struct {
+	Name string
+	Path string
+	// Dir is the directory of the package, where its tests run
+	Dir string
+	// DefinedTypes contains the types declared in the package and
+	// the exported types of the packages it imports, qualified by package name
+	DefinedTypes []string
+	StructInfos  map[string]StructInfo
+	// Interfaces contains the interfaces declared in the package and the exported interfaces of the packages it imports
+	Interfaces map[string]InterfaceInfo
+	Handlers   map[string]HandlerInfo
+	Funcs      map[string]FuncInfo
+	// Imports maps the names of the packages imported by the package to their import paths
+	Imports map[string]string
+}
+
+// FuncInfo describes a package level function, variable of function type or method.
+// Methods are keyed by the name of their receiver type and their name, e.g. "Server.GetUser".
+type FuncInfo struct {
+	Signature string
+	// Handler is set for signatures compatible with http.HandlerFunc
+	Handler bool
+	// Constructs is the type returned by functions taking no arguments, optionally along with an error,
+	// ReturnsError is set when they return an error as well
+	Constructs   string
+	ReturnsError bool
+	// Router is set for functions constructing an http.Handler
+	Router bool
+	// Receiver is the type declaring a method
+	Receiver string
+}
+
+// constructedType returns the type constructed by a signature taking no arguments and returning a value,
+// optionally along with an error, and whether it returns an error
+func constructedType(sig *types.Signature) (types.Type, bool, bool) {
+	results := sig.Results()
+	if sig.Params().Len() > 0 || results.Len() == 0 || results.Len() > 2 {
+		return nil, false, false
+	}
+	if results.Len() == 2 && types.TypeString(results.At(1).Type(), nil) != "error" {
+		return nil, false, false
+	}
+	return results.At(0).Type(), results.Len() == 2, true
+}
+
+// implementsHandler reports whether a type, or a pointer to it, has a ServeHTTP method compatible with http.HandlerFunc
+func implementsHandler(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "ServeHTTP")
+	method, ok := obj.(*types.Func)
+	return ok && isHandlerSignature(method.Type().(*types.Signature))
+}
+
+// loadPackage loads the package containing the input, which can be either a Go file or a package directory,
+// and extracts its defined types, struct information and the request and response types used by http handlers.
+// Types declared in other files of the package and in imported packages are resolved as well.
+func loadPackage(input string) (PackageInfo, error) {
+	stat, err := os.Stat(input)
+	if err != nil {
+		return PackageInfo{}, fmt.Errorf("could not stat input %s: %w", input, err)
+	}
+
+	var (
+		dir     = input
+		pattern = "."
+	)
+	if !stat.IsDir() {
+		abs, err := filepath.Abs(input)
+		if err != nil {
+			return PackageInfo{}, fmt.Errorf("could not resolve input %s: %w", input, err)
+		}
+		dir, pattern = filepath.Dir(abs), "file="+abs
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Mode: loadMode, Dir: dir}, pattern)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("could not parse file %s: %w", filename, err)
+		return PackageInfo{}, fmt.Errorf("could not load package %s: %w", input, err)
+	}
+
+	if len(pkgs) != 1 {
+		return PackageInfo{}, fmt.Errorf("expected one package in %s, found %d", input, len(pkgs))
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		var errs []error
+		for _, pkgErr := range pkg.Errors {
+			errs = append(errs, pkgErr)
+		}
+		return PackageInfo{}, fmt.Errorf("could not load package %s: %w", input, errors.Join(errs...))
+	}
+
+	info := PackageInfo{
+		Name:        pkg.Name,
+		Path:        pkg.PkgPath,
+		Dir:         dir,
+		StructInfos: make(map[string]StructInfo),
+		Imports:     make(map[string]string),
 	}
 
 	var (
-		packageName  = file.Name.Name
-		definedTypes []string
-		structInfos  = make(map[string]StructInfo)
+		qualifier = packageQualifier(pkg.Types)
+		collector = newTypeCollector(&info, pkg.Types)
 	)
 
-	// Walk through declarations to find type definitions
-	for _, decl := range file.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
+	// Collect the types declared in the package.
+	collector.collectScope(pkg.Types)
+
+	// Collect the exported types of the imported packages.
+	for _, imported := range pkg.Types.Imports() {
+		if _, ok := info.Imports[imported.Name()]; ok {
 			continue
 		}
+		info.Imports[imported.Name()] = imported.Path()
+		collector.collectScope(imported)
+	}
+
+	sort.Strings(info.DefinedTypes)
+
+	info.Funcs = collectFuncs(pkg.Types, qualifier)
+
+	info.Handlers = make(map[string]HandlerInfo)
+	for _, file := range pkg.Syntax {
+		for name, handler := range analyzeHandlers(file, pkg.Types, pkg.TypesInfo, qualifier) {
+			info.Handlers[name] = handler
+		}
+	}
 
-		for _, spec := range genDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
+	return info, nil
+}
+
+// collectFuncs collects the package level functions, variables of function type and methods declared in a package
+func collectFuncs(pkg *types.Package, qualifier types.Qualifier) map[string]FuncInfo {
+	funcs := make(map[string]FuncInfo)
+	for _, name := range pkg.Scope().Names() {
+		switch obj := pkg.Scope().Lookup(name).(type) {
+		case *types.Func, *types.Var:
+			sig, ok := obj.Type().Underlying().(*types.Signature)
 			if !ok {
 				continue
 			}
 
-			typeName := typeSpec.Name.Name
-			definedTypes = append(definedTypes, typeName)
-
-			// If it's a struct, extract field information
-			structType, ok := typeSpec.Type.(*ast.StructType)
-			if !ok {
+			funcInfo := FuncInfo{
+				Signature: types.TypeString(sig, qualifier),
+				Handler:   isHandlerSignature(sig),
+			}
+			if constructs, returnsError, ok := constructedType(sig); ok {
+				funcInfo.Constructs = types.TypeString(constructs, qualifier)
+				funcInfo.ReturnsError = returnsError
+				funcInfo.Router = implementsHandler(constructs)
+			}
+			funcs[name] = funcInfo
+		case *types.TypeName:
+			// Methods declared on pointer receivers are in the method set of the pointer type
+			named, ok := obj.Type().(*types.Named)
+			if !ok || types.IsInterface(named) {
 				continue
 			}
+			methods := types.NewMethodSet(types.NewPointer(named))
+			for i := range methods.Len() {
+				method := methods.At(i).Obj().(*types.Func)
+				sig := method.Type().(*types.Signature)
+				funcs[name+"."+method.Name()] = FuncInfo{
+					Signature: types.TypeString(sig, qualifier),
+					Handler:   isHandlerSignature(sig),
+					Receiver:  name,
+				}
+			}
+		}
+	}
+	return funcs
+}
 
-			structInfos[typeName] = parseStructType(typeName, structType)
+// packageQualifier qualifies types by the name of their package, unless they are declared in pkg
+func packageQualifier(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
 		}
+		return other.Name()
 	}
+}
 
-	return packageName, definedTypes, structInfos, nil
+// typeCollector collects the types declared in a package and the struct information
+// of every named struct type reachable from them, whichever package declares it.
+type typeCollector struct {
+	info      *PackageInfo
+	pkg       *types.Package
+	qualifier types.Qualifier
+	// expanding holds the type infos of the named non struct types being described, see elemTypeInfo
+	expanding map[*types.Named]*TypeInfo
 }
 
-// parseStructType extracts field information from a struct type
-func parseStructType(structName string, structType *ast.StructType) StructInfo {
-	info := StructInfo{
-		Name:   structName,
-		Fields: []StructField{},
+func newTypeCollector(info *PackageInfo, pkg *types.Package) *typeCollector {
+	if info.StructInfos == nil {
+		info.StructInfos = make(map[string]StructInfo)
+	}
+	if info.Imports == nil {
+		info.Imports = make(map[string]string)
+	}
+	if info.Interfaces == nil {
+		info.Interfaces = make(map[string]InterfaceInfo)
 	}
 
-	for _, field := range structType.Fields.List {
-		for _, name := range field.Names {
-			fieldInfo := StructField{
-				Name:   name.Name,
-				Type:   getTypeString(field.Type),
-				GoType: getGoTypeString(field.Type),
-			}
+	return &typeCollector{
+		info:      info,
+		pkg:       pkg,
+		qualifier: packageQualifier(pkg),
+		expanding: make(map[*types.Named]*TypeInfo),
+	}
+}
 
+// collectScope collects the named types declared in the scope of a package.
+// Only exported types are collected for packages other than the package under test.
+func (c *typeCollector) collectScope(pkg *types.Package) {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		typeName, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || (pkg != c.pkg && !typeName.Exported()) {
+			continue
+		}
+
+		c.info.DefinedTypes = append(c.info.DefinedTypes, types.TypeString(typeName.Type(), c.qualifier))
+
+		// If it's a struct, extract field information, if it's an interface, the methods its mocks expect
+		switch typeName.Type().Underlying().(type) {
+		case *types.Struct:
+			c.collectStruct(typeName.Type(), typeName.Pkg())
+		case *types.Interface:
+			c.collectInterface(typeName)
+		}
+	}
+}
+
+// collectInterface extracts the parameter and result types of the methods of a non generic named interface type
+func (c *typeCollector) collectInterface(typeName *types.TypeName) {
+	if named, ok := typeName.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		return
+	}
+
+	var (
+		iface        = typeName.Type().Underlying().(*types.Interface)
+		exportedOnly = typeName.Pkg() != c.pkg
+		info         = InterfaceInfo{
+			Name:    types.TypeString(typeName.Type(), c.qualifier),
+			Methods: make(map[string]MethodInfo, iface.NumMethods()),
+		}
+	)
+	for i := range iface.NumMethods() {
+		sig := iface.Method(i).Type().(*types.Signature)
+
+		method := MethodInfo{Variadic: sig.Variadic()}
+		for j := range sig.Params().Len() {
+			method.Params = append(method.Params, c.newTypeInfo(sig.Params().At(j).Type(), exportedOnly))
+		}
+		for j := range sig.Results().Len() {
+			method.Results = append(method.Results, c.newTypeInfo(sig.Results().At(j).Type(), exportedOnly))
+		}
+		info.Methods[iface.Method(i).Name()] = method
+	}
+
+	c.info.Interfaces[info.Name] = info
+}
+
+// collectStruct extracts the field information of a named (or aliased) struct type declared in typePkg,
+// unless already collected
+func (c *typeCollector) collectStruct(t types.Type, typePkg *types.Package) {
+	qualifiedName := types.TypeString(t, c.qualifier)
+	if _, ok := c.info.StructInfos[qualifiedName]; ok || typePkg == nil {
+		return
+	}
+
+	// Packages declaring nested types might not be imported by the package under test.
+	if typePkg != c.pkg {
+		if _, ok := c.info.Imports[typePkg.Name()]; !ok {
+			c.info.Imports[typePkg.Name()] = typePkg.Path()
+		}
+	}
+
+	// Register the struct before parsing its fields to stop on recursive types.
+	c.info.StructInfos[qualifiedName] = StructInfo{Name: qualifiedName}
+	c.info.StructInfos[qualifiedName] = StructInfo{
+		Name:   qualifiedName,
+		Fields: c.parseStructFields(t.Underlying().(*types.Struct), typePkg != c.pkg),
+	}
+}
+
+// parseStructFields extracts the fields of a struct type
+func (c *typeCollector) parseStructFields(structType *types.Struct, exportedOnly bool) []StructField {
+	fields := []StructField{}
+
+	for i := range structType.NumFields() {
+		field := structType.Field(i)
+		if exportedOnly && !field.Exported() {
+			continue
+		}
+
+		fieldInfo := StructField{
+			Name:     field.Name(),
+			Embedded: field.Embedded(),
+			TypeInfo: c.newTypeInfo(field.Type(), exportedOnly),
+		}
+
+		jsonTag, options := extractJSONTag(structType.Tag(i))
+		fieldInfo.OmitEmpty = slices.Contains(options, "omitempty") || slices.Contains(options, "omitzero")
+		switch {
+		case jsonTag == "-" && options == nil:
+			// Fields tagged with "-" are skipped by encoding/json, "-," names a field "-"
+			fieldInfo.Ignored = true
+			fieldInfo.JSONTag = jsonTag
+		case jsonTag != "":
+			fieldInfo.JSONTag = jsonTag
+		case fieldInfo.Embedded && isStructType(fieldInfo.TypeInfo):
+			// Embedded structs without a name in their tag have their fields promoted
+		default:
 			// Default to lowercase field name if no tag
 			fieldInfo.JSONTag = strings.ToLower(fieldInfo.Name)
+		}
 
-			// Extract JSON tag if present
-			if field.Tag != nil {
-				if jsonTag := extractJSONTag(field.Tag.Value); jsonTag != "" {
-					fieldInfo.JSONTag = jsonTag
-				}
-			}
+		fields = append(fields, fieldInfo)
+	}
 
-			info.Fields = append(info.Fields, fieldInfo)
+	return fields
+}
+
+// isStructType reports whether a type is a struct or a pointer to a struct
+func isStructType(t TypeInfo) bool {
+	if t.GoType == "pointer" {
+		return t.Elem.GoType == "struct"
+	}
+	return t.GoType == "struct"
+}
+
+// newTypeInfo describes a type for code generation.
+// Named struct types are not expanded, their struct information is collected and looked up by name.
+func (c *typeCollector) newTypeInfo(t types.Type, exportedOnly bool) TypeInfo {
+	info := TypeInfo{
+		Type:   getTypeString(t, c.qualifier),
+		GoType: getGoTypeString(t),
+		Bits:   getBitSize(t),
+	}
+
+	switch u := t.(type) {
+	case *types.Named:
+		// Pointers, slices and maps need their element types even when named.
+		if _, ok := u.Underlying().(*types.Struct); ok {
+			c.collectStruct(u, u.Obj().Pkg())
+			return info
+		}
+		c.expanding[u] = &info
+		defer delete(c.expanding, u)
+	case *types.Alias:
+		if _, ok := u.Underlying().(*types.Struct); ok {
+			c.collectStruct(u, u.Obj().Pkg())
+			return info
 		}
+	case *types.Struct:
+		info.Fields = c.parseStructFields(u, exportedOnly)
+		return info
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		info.Elem = c.elemTypeInfo(u.Elem(), exportedOnly)
+	case *types.Slice:
+		info.Elem = c.elemTypeInfo(u.Elem(), exportedOnly)
+	case *types.Array:
+		info.Elem = c.elemTypeInfo(u.Elem(), exportedOnly)
+	case *types.Map:
+		info.Key, info.Elem = c.elemTypeInfo(u.Key(), exportedOnly), c.elemTypeInfo(u.Elem(), exportedOnly)
 	}
 
 	return info
 }
 
-// getTypeString converts an ast.Expr to a type string
-func getTypeString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.SelectorExpr:
-		return getTypeString(t.X) + "." + t.Sel.Name
-	case *ast.StarExpr:
-		return "*" + getTypeString(t.X)
-	case *ast.ArrayType:
-		return "[]" + getTypeString(t.Elt)
-	default:
-		return "any"
+// elemTypeInfo describes the element or key type of a pointer, slice, array or map.
+// Named types being described are referenced instead of described again, so that recursive types
+// such as type Tree map[string]Tree are their own element type rather than expanding forever.
+func (c *typeCollector) elemTypeInfo(t types.Type, exportedOnly bool) *TypeInfo {
+	if named, ok := t.(*types.Named); ok {
+		if info, ok := c.expanding[named]; ok {
+			return info
+		}
 	}
+	info := c.newTypeInfo(t, exportedOnly)
+	return &info
 }
 
-// getGoTypeString returns the base Go type for code generation
-func getGoTypeString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		switch t.Name {
-		case "string":
+// getTypeString converts a type to a type string
+func getTypeString(t types.Type, qualifier types.Qualifier) string {
+	return types.TypeString(t, qualifier)
+}
+
+// getGoTypeString returns the kind of the underlying type for code generation
+func getGoTypeString(t types.Type) string {
+	if isRawMessage(t) {
+		return "raw"
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
 			return "string"
-		case "int", "int8", "int16", "int32", "int64":
-			return "int"
-		case "uint", "uint8", "uint16", "uint32", "uint64":
+		case u.Info()&types.IsUnsigned != 0:
 			return "uint"
-		case "float32", "float64":
+		case u.Info()&types.IsInteger != 0:
+			return "int"
+		case u.Info()&types.IsFloat != 0:
 			return "float"
-		case "bool":
+		case u.Info()&types.IsBoolean != 0:
 			return "bool"
 		default:
-			return "struct" // Custom types
+			return "unsupported"
 		}
-	case *ast.StarExpr:
+	case *types.Struct:
+		return "struct"
+	case *types.Pointer:
 		return "pointer"
-	case *ast.ArrayType:
+	case *types.Slice:
 		return "slice"
+	case *types.Array:
+		return "array"
+	case *types.Map:
+		return "map"
+	case *types.Interface:
+		if u.Empty() {
+			return "any"
+		}
+		return "unsupported"
 	default:
-		return "interface"
+		return "unsupported"
 	}
 }
 
-// extractJSONTag extracts the json tag value from a struct tag
-func extractJSONTag(tag string) string {
-	// Look for json:"fieldname"
-	parts := strings.Split(strings.Trim(tag, "`"), " ")
-	for _, part := range parts {
-		if !strings.HasPrefix(part, `json:"`) {
-			continue
+// getBitSize returns the size in bits of integer and float types, or 0 for other types.
+// Generated tests are assumed to run on 64-bit platforms, where int and uint are 64 bits wide.
+func getBitSize(t types.Type) int {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsInteger|types.IsFloat) == 0 {
+		return 0
+	}
+	return int(types.SizesFor("gc", "amd64").Sizeof(basic) * 8)
+}
+
+// isRawMessage reports whether a type is json.RawMessage, which
+// newer toolchains declare as an alias of jsontext.Value
+func isRawMessage(t types.Type) bool {
+	for {
+		var obj *types.TypeName
+		switch n := t.(type) {
+		case *types.Alias:
+			obj = n.Obj()
+		case *types.Named:
+			obj = n.Obj()
+		default:
+			return false
 		}
 
-		jsonPart := strings.TrimPrefix(part, `json:"`)
-		jsonPart = strings.TrimSuffix(jsonPart, `"`)
+		if obj.Pkg() != nil {
+			switch obj.Pkg().Path() + "." + obj.Name() {
+			case "encoding/json.RawMessage", "encoding/json/jsontext.Value":
+				return true
+			}
+		}
 
-		// Handle json:",omitempty" etc.
-		if strings.Contains(jsonPart, ",") {
-			jsonPart = strings.Split(jsonPart, ",")[0]
+		alias, ok := t.(*types.Alias)
+		if !ok {
+			return false
 		}
+		t = alias.Rhs()
+	}
+}
 
-		return jsonPart
+// extractJSONTag extracts the name and the options of the json tag of a struct field
+func extractJSONTag(tag string) (string, []string) {
+	value, ok := reflect.StructTag(strings.Trim(tag, "`")).Lookup("json")
+	if !ok {
+		return "", nil
 	}
 
-	return ""
+	name, options, found := strings.Cut(value, ",")
+	if !found {
+		return name, nil
+	}
+	return name, strings.Split(options, ",")
 }
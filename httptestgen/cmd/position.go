@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// position is a location in a file, lines and columns start at 1
+type position struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// specIndex locates the values and object keys of a spec by their path, the keys and indexes leading to them
+type specIndex struct {
+	file string
+	data []byte
+	// json is the spec as JSON, converted from YAML specs
+	json   []byte
+	values map[string]int
+	keys   map[string]int
+}
+
+// indexJSON records the offsets of every value and object key of a JSON document
+func indexJSON(file string, data []byte) (*specIndex, error) {
+	idx := &specIndex{
+		file:   file,
+		data:   data,
+		json:   data,
+		values: make(map[string]int),
+		keys:   make(map[string]int),
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := idx.indexValue(decoder, nil); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+func (idx *specIndex) indexValue(decoder *json.Decoder, path []string) error {
+	start := idx.skipSeparators(int(decoder.InputOffset()))
+
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	idx.values[pathKey(path)] = start
+
+	switch tok {
+	case json.Delim('{'):
+		for decoder.More() {
+			keyStart := idx.skipSeparators(int(decoder.InputOffset()))
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			keyPath := append(path[:len(path):len(path)], key.(string))
+			idx.keys[pathKey(keyPath)] = keyStart
+			if err := idx.indexValue(decoder, keyPath); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			if err := idx.indexValue(decoder, append(path[:len(path):len(path)], strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	}
+
+	return err
+}
+
+// skipSeparators returns the offset of the first token at or after offset
+func (idx *specIndex) skipSeparators(offset int) int {
+	for offset < len(idx.data) && strings.IndexByte(" \t\r\n,:", idx.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// pathKey joins the elements of a path, which can contain any character
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// valuePos returns the position of the value at path, or of its closest indexed parent
+func (idx *specIndex) valuePos(path []string) position {
+	for ; len(path) > 0; path = path[:len(path)-1] {
+		if offset, ok := idx.values[pathKey(path)]; ok {
+			return idx.offsetPos(offset)
+		}
+	}
+	return idx.offsetPos(idx.values[""])
+}
+
+// keyPos returns the position of the object key at path, falling back to the position of its value
+func (idx *specIndex) keyPos(path []string) position {
+	if offset, ok := idx.keys[pathKey(path)]; ok {
+		return idx.offsetPos(offset)
+	}
+	return idx.valuePos(path)
+}
+
+// findKeyPos returns the position of the first object key named name below the value at path,
+// falling back to the position of the value
+func (idx *specIndex) findKeyPos(path []string, name string) position {
+	offset := -1
+	for key, keyOffset := range idx.keys {
+		keyPath := strings.Split(key, "\x00")
+		if len(keyPath) > len(path) && keyPath[len(keyPath)-1] == name && slices.Equal(keyPath[:len(path)], path) &&
+			(offset < 0 || keyOffset < offset) {
+			offset = keyOffset
+		}
+	}
+	if offset < 0 {
+		return idx.valuePos(path)
+	}
+	return idx.offsetPos(offset)
+}
+
+// offsetPos converts a byte offset to a position
+func (idx *specIndex) offsetPos(offset int) position {
+	offset = min(offset, len(idx.data))
+	before := idx.data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(before, '\n')
+	return position{File: idx.file, Line: line, Col: col}
+}
+
+// yamlErrorPattern matches the line YAML syntax errors are reported at
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// syntaxDiagnostic positions a JSON or YAML syntax error
+func syntaxDiagnostic(file string, data []byte, err error) diagnostic {
+	idx := &specIndex{file: file, data: data}
+
+	var (
+		syntaxErr *json.SyntaxError
+		yamlErr   *yamlError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		return diagnostic{Pos: idx.offsetPos(max(int(syntaxErr.Offset)-1, 0)), Msg: err.Error()}
+	case errors.As(err, &yamlErr):
+		return diagnostic{Pos: yamlErr.Pos, Msg: yamlErr.Msg}
+	}
+
+	if match := yamlErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return diagnostic{Pos: position{File: file, Line: line, Col: 1}, Msg: match[2]}
+	}
+	return diagnostic{Pos: idx.offsetPos(len(data)), Msg: err.Error()}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"slices"
+	"strings"
+)
+
+// specError is a problem of a function spec, found at one of its properties
+type specError struct {
+	Property string
+	Msg      string
+}
+
+func (e *specError) Error() string {
+	return e.Msg
+}
+
+// resolveHandler resolves how the tests of a function spec reach the handler under test: through the http.Handler
+// built by its router, by calling a method on the receiver built by its constructor, or by calling a function.
+// Types implementing http.Handler are tested through their ServeHTTP method.
+func resolveHandler(spec *FunctionTestSpec, pkgInfo PackageInfo) error {
+	if spec.Router != "" {
+		funcInfo, ok := pkgInfo.Funcs[spec.Router]
+		switch {
+		case spec.Constructor != "":
+			return &specError{"constructor", fmt.Sprintf("%s is served by router %s, which cannot be used with a constructor", spec.Func, spec.Router)}
+		case !ok:
+			return &specError{"router", fmt.Sprintf("%s is not declared in package %s", spec.Router, pkgInfo.Name)}
+		case !funcInfo.Router:
+			return &specError{"router", fmt.Sprintf("%s has signature %s, which does not return an http.Handler", spec.Router, funcInfo.Signature)}
+		}
+		spec.RouterReturnsError = funcInfo.ReturnsError
+		return nil
+	}
+
+	typeName, method, isMethod := strings.Cut(spec.Func, ".")
+	if !isMethod {
+		if funcInfo, ok := pkgInfo.Funcs[spec.Func]; ok {
+			switch {
+			case spec.Constructor != "":
+				return &specError{"constructor", fmt.Sprintf("%s is a function, only methods and types implementing http.Handler need a constructor", spec.Func)}
+			case !funcInfo.Handler:
+				return &specError{"func", fmt.Sprintf("%s has signature %s, which is not compatible with http.HandlerFunc", spec.Func, funcInfo.Signature)}
+			}
+			return nil
+		}
+		method = "ServeHTTP"
+	}
+
+	if !slices.Contains(pkgInfo.DefinedTypes, typeName) {
+		return &specError{"func", fmt.Sprintf("%s is not declared in package %s", typeName, pkgInfo.Name)}
+	}
+
+	funcInfo, ok := pkgInfo.Funcs[typeName+"."+method]
+	switch {
+	case !isMethod && (!ok || !funcInfo.Handler):
+		return &specError{"func", fmt.Sprintf("%s does not implement http.Handler", typeName)}
+	case !ok:
+		return &specError{"func", fmt.Sprintf("%s has no method %s", typeName, method)}
+	case !funcInfo.Handler:
+		return &specError{"func", fmt.Sprintf("%s has signature %s, which is not compatible with http.HandlerFunc", spec.Func, funcInfo.Signature)}
+	case spec.Constructor == "":
+		return &specError{"func", fmt.Sprintf("%s needs a constructor building a %s", spec.Func, typeName)}
+	}
+
+	// Constructors are either functions of the package, which are called, or expressions used as they are
+	receiver := spec.Constructor
+	if constructor, ok := pkgInfo.Funcs[spec.Constructor]; ok {
+		if constructor.Constructs != typeName && constructor.Constructs != "*"+typeName {
+			return &specError{"constructor", fmt.Sprintf("%s has signature %s, which does not construct a %s", spec.Constructor, constructor.Signature, typeName)}
+		}
+		receiver = spec.Constructor + "()"
+		spec.ConstructorReturnsError = constructor.ReturnsError
+	} else if _, err := parser.ParseExpr(spec.Constructor); err != nil {
+		return &specError{"constructor", fmt.Sprintf("invalid constructor %q: %v", spec.Constructor, err)}
+	}
+
+	spec.Receiver, spec.Method, spec.ReceiverExpr = typeName, method, receiver
+	return nil
+}
+
+// handlerName returns the name of the handler under test, methods being qualified by their receiver type
+func (spec FunctionTestSpec) handlerName() string {
+	if spec.Receiver != "" {
+		return spec.Receiver + "." + spec.Method
+	}
+	return spec.Func
+}
+
+// testName returns the name of the test function generated for a function spec,
+// e.g. TestServer_GetUser for the method GetUser of Server
+func testName(funcName string) string {
+	name := strings.ReplaceAll(funcName, ".", "_")
+	// Test functions whose name continues with a lowercase letter are not run
+	return "Test" + strings.ToUpper(name[:1]) + name[1:]
+}
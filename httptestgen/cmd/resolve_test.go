@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+)
+
+const receiversSrc = `package users
+
+import (
+	"errors"
+	"net/http"
+)
+
+type (
+	Server  struct{ name string }
+	Mux     struct{ *http.ServeMux }
+	Counter int
+)
+
+func NewServer() (*Server, error) { return nil, errors.New("unimplemented") }
+
+func NewCounter() Counter { return 0 }
+
+func (s *Server) GetUser(w http.ResponseWriter, r *http.Request) {}
+
+func (s Server) Name() string { return s.name }
+
+func Health(w http.ResponseWriter, r *http.Request) {}
+`
+
+func TestResolveHandler(t *testing.T) {
+	info, _, _, pkg := loadTestPackage(t, receiversSrc)
+	info.Funcs = collectFuncs(pkg, packageQualifier(pkg))
+
+	t.Run("it should call methods on the receiver built by their constructor", func(t *testing.T) {
+		for _, tt := range []struct {
+			spec     FunctionTestSpec
+			expected FunctionTestSpec
+		}{
+			{
+				spec: FunctionTestSpec{Func: "Server.GetUser", Constructor: "NewServer"},
+				expected: FunctionTestSpec{
+					Func:                    "Server.GetUser",
+					Constructor:             "NewServer",
+					Receiver:                "Server",
+					Method:                  "GetUser",
+					ReceiverExpr:            "NewServer()",
+					ConstructorReturnsError: true,
+				},
+			},
+			{
+				spec: FunctionTestSpec{Func: "Mux", Constructor: "Mux{http.NewServeMux()}"},
+				expected: FunctionTestSpec{
+					Func:         "Mux",
+					Constructor:  "Mux{http.NewServeMux()}",
+					Receiver:     "Mux",
+					Method:       "ServeHTTP",
+					ReceiverExpr: "Mux{http.NewServeMux()}",
+				},
+			},
+			{
+				spec:     FunctionTestSpec{Func: "Health"},
+				expected: FunctionTestSpec{Func: "Health"},
+			},
+		} {
+			spec := tt.spec
+			if err := resolveHandler(&spec, info); err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.spec.Func, err)
+			}
+			if spec.Receiver != tt.expected.Receiver ||
+				spec.Method != tt.expected.Method ||
+				spec.ReceiverExpr != tt.expected.ReceiverExpr ||
+				spec.ConstructorReturnsError != tt.expected.ConstructorReturnsError {
+				t.Fatalf("unexpected spec:\ngot:  %+v\nwant: %+v", spec, tt.expected)
+			}
+		}
+	})
+
+	for _, tt := range []struct {
+		name     string
+		spec     FunctionTestSpec
+		property string
+		expected string
+	}{
+		{
+			name:     "methods without a constructor",
+			spec:     FunctionTestSpec{Func: "Server.GetUser"},
+			property: "func",
+			expected: "Server.GetUser needs a constructor building a Server",
+		},
+		{
+			name:     "missing methods",
+			spec:     FunctionTestSpec{Func: "Server.DeleteUser", Constructor: "NewServer"},
+			property: "func",
+			expected: "Server has no method DeleteUser",
+		},
+		{
+			name:     "methods that are not handlers",
+			spec:     FunctionTestSpec{Func: "Server.Name", Constructor: "NewServer"},
+			property: "func",
+			expected: "Server.Name has signature func() string, which is not compatible with http.HandlerFunc",
+		},
+		{
+			name:     "types not implementing http.Handler",
+			spec:     FunctionTestSpec{Func: "Counter", Constructor: "NewCounter"},
+			property: "func",
+			expected: "Counter does not implement http.Handler",
+		},
+		{
+			name:     "constructors of other types",
+			spec:     FunctionTestSpec{Func: "Server.GetUser", Constructor: "NewCounter"},
+			property: "constructor",
+			expected: "NewCounter has signature func() Counter, which does not construct a Server",
+		},
+		{
+			name:     "constructors that are not expressions",
+			spec:     FunctionTestSpec{Func: "Server.GetUser", Constructor: "&Server{"},
+			property: "constructor",
+			expected: `invalid constructor "&Server{": 1:9: expected '}', found 'EOF'`,
+		},
+		{
+			name:     "functions with a constructor",
+			spec:     FunctionTestSpec{Func: "Health", Constructor: "NewServer"},
+			property: "constructor",
+			expected: "Health is a function, only methods and types implementing http.Handler need a constructor",
+		},
+	} {
+		t.Run("it should report "+tt.name, func(t *testing.T) {
+			err := resolveHandler(&tt.spec, info)
+
+			specErr, ok := err.(*specError)
+			if !ok {
+				t.Fatalf("expected a spec error, got %v", err)
+			}
+			if specErr.Property != tt.property || specErr.Msg != tt.expected {
+				t.Fatalf("unexpected error:\ngot:  %s: %s\nwant: %s: %s", specErr.Property, specErr.Msg, tt.property, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTestName(t *testing.T) {
+	t.Run("it should name tests of methods after their receiver and run tests of unexported handlers", func(t *testing.T) {
+		for funcName, expected := range map[string]string{
+			"CreateUserHandler": "TestCreateUserHandler",
+			"Server.GetUser":    "TestServer_GetUser",
+			"server.getUser":    "TestServer_getUser",
+		} {
+			if got := testName(funcName); got != expected {
+				t.Errorf("testName(%q) = %q, want %q", funcName, got, expected)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusCode is the status code of a response, declared in specs as a number, a numeric string or the name
+// of its net/http constant, e.g. 201, "201", "Created", "StatusCreated" or "http.StatusCreated"
+type StatusCode int
+
+// statusConstants maps the status codes known to net/http to the names of their constants
+var statusConstants = map[int]string{
+	100: "StatusContinue",
+	101: "StatusSwitchingProtocols",
+	102: "StatusProcessing",
+	103: "StatusEarlyHints",
+	200: "StatusOK",
+	201: "StatusCreated",
+	202: "StatusAccepted",
+	203: "StatusNonAuthoritativeInfo",
+	204: "StatusNoContent",
+	205: "StatusResetContent",
+	206: "StatusPartialContent",
+	207: "StatusMultiStatus",
+	208: "StatusAlreadyReported",
+	226: "StatusIMUsed",
+	300: "StatusMultipleChoices",
+	301: "StatusMovedPermanently",
+	302: "StatusFound",
+	303: "StatusSeeOther",
+	304: "StatusNotModified",
+	305: "StatusUseProxy",
+	307: "StatusTemporaryRedirect",
+	308: "StatusPermanentRedirect",
+	400: "StatusBadRequest",
+	401: "StatusUnauthorized",
+	402: "StatusPaymentRequired",
+	403: "StatusForbidden",
+	404: "StatusNotFound",
+	405: "StatusMethodNotAllowed",
+	406: "StatusNotAcceptable",
+	407: "StatusProxyAuthRequired",
+	408: "StatusRequestTimeout",
+	409: "StatusConflict",
+	410: "StatusGone",
+	411: "StatusLengthRequired",
+	412: "StatusPreconditionFailed",
+	413: "StatusRequestEntityTooLarge",
+	414: "StatusRequestURITooLong",
+	415: "StatusUnsupportedMediaType",
+	416: "StatusRequestedRangeNotSatisfiable",
+	417: "StatusExpectationFailed",
+	418: "StatusTeapot",
+	421: "StatusMisdirectedRequest",
+	422: "StatusUnprocessableEntity",
+	423: "StatusLocked",
+	424: "StatusFailedDependency",
+	425: "StatusTooEarly",
+	426: "StatusUpgradeRequired",
+	428: "StatusPreconditionRequired",
+	429: "StatusTooManyRequests",
+	431: "StatusRequestHeaderFieldsTooLarge",
+	451: "StatusUnavailableForLegalReasons",
+	500: "StatusInternalServerError",
+	501: "StatusNotImplemented",
+	502: "StatusBadGateway",
+	503: "StatusServiceUnavailable",
+	504: "StatusGatewayTimeout",
+	505: "StatusHTTPVersionNotSupported",
+	506: "StatusVariantAlsoNegotiates",
+	507: "StatusInsufficientStorage",
+	508: "StatusLoopDetected",
+	510: "StatusNotExtended",
+	511: "StatusNetworkAuthenticationRequired",
+}
+
+// statusCodeError reports a status code net/http does not know
+type statusCodeError struct {
+	Value string
+}
+
+func (e *statusCodeError) Error() string {
+	return fmt.Sprintf("unknown status code %q", e.Value)
+}
+
+// parseStatusCode parses a status code given as a number or as the name of its net/http constant
+func parseStatusCode(value string) (StatusCode, error) {
+	if code, err := strconv.Atoi(value); err == nil {
+		if _, ok := statusConstants[code]; ok {
+			return StatusCode(code), nil
+		}
+		return 0, &statusCodeError{Value: value}
+	}
+
+	name := strings.TrimPrefix(value, "http.")
+	if !strings.HasPrefix(name, "Status") {
+		name = "Status" + name
+	}
+	for code, constant := range statusConstants {
+		if strings.EqualFold(constant, name) {
+			return StatusCode(code), nil
+		}
+	}
+	return 0, &statusCodeError{Value: value}
+}
+
+// UnmarshalJSON decodes a status code declared either as a number or as a string
+func (c *StatusCode) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		value = string(data)
+	}
+
+	code, err := parseStatusCode(value)
+	if err != nil {
+		return err
+	}
+	*c = code
+	return nil
+}
+
+// String returns the status code as a number
+func (c StatusCode) String() string {
+	return strconv.Itoa(int(c))
+}
+
+// Constant returns the net/http constant of the status code, as written in Go code
+func (c StatusCode) Constant() string {
+	if constant, ok := statusConstants[int(c)]; ok {
+		return "http." + constant
+	}
+	return c.String()
+}
+
+// StatusTypes maps status codes to types. Status codes are declared like StatusCode and stored as numbers.
+type StatusTypes map[string]string
+
+// UnmarshalJSON decodes types by status code, normalizing the status codes
+func (t *StatusTypes) UnmarshalJSON(data []byte) error {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	types := make(StatusTypes, len(raw))
+	for value, typeName := range raw {
+		code, err := parseStatusCode(value)
+		if err != nil {
+			return err
+		}
+		if _, ok := types[code.String()]; ok {
+			return fmt.Errorf("duplicate status code %s", code)
+		}
+		types[code.String()] = typeName
+	}
+
+	*t = types
+	return nil
+}
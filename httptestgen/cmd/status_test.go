@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+)
+
+func TestStatusCode(t *testing.T) {
+	t.Run("it should accept numbers, numeric strings and net/http constant names", func(t *testing.T) {
+		for _, value := range []string{`201`, `"201"`, `"Created"`, `"StatusCreated"`, `"http.StatusCreated"`} {
+			var code StatusCode
+			if err := json.Unmarshal([]byte(value), &code); err != nil {
+				t.Fatalf("unexpected error decoding %s: %v", value, err)
+			}
+			if code != 201 || code.Constant() != "http.StatusCreated" {
+				t.Fatalf("unexpected status code for %s: %d (%s)", value, code, code.Constant())
+			}
+		}
+	})
+
+	t.Run("it should reject status codes unknown to net/http", func(t *testing.T) {
+		for _, value := range []string{`299`, `"99"`, `"Teapots"`, `201.5`, `true`} {
+			var code StatusCode
+			if err := json.Unmarshal([]byte(value), &code); err == nil {
+				t.Fatalf("expected an error decoding %s, got %d", value, code)
+			}
+		}
+	})
+
+	t.Run("it should normalize the status codes of response types", func(t *testing.T) {
+		var types StatusTypes
+		if err := json.Unmarshal([]byte(`{"Created": "CreateUserResponse", "400": "ErrorResponse"}`), &types); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := StatusTypes{"201": "CreateUserResponse", "400": "ErrorResponse"}
+		if !maps.Equal(expected, types) {
+			t.Fatalf("unexpected response types: %v", types)
+		}
+
+		if err := json.Unmarshal([]byte(`{"Created": "A", "201": "B"}`), &types); err == nil {
+			t.Fatal("expected an error for duplicate status codes, got none")
+		}
+	})
+}
@@ -1,26 +1,62 @@
 // Code generated by httptestgen. DO NOT EDIT.
 
 package {{.PackageName}}
-
-import (
-    "bytes"
-    "context"
-    "encoding/json"
-    "io"
-    "net/http/httptest"
-    "testing"
-    "time"
-)
 {{- range $funcSpec := .FunctionSpecs}}
-func Test{{$funcSpec.Func}}(t *testing.T) {
+func {{testName $funcSpec.Func}}(t *testing.T) {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 {{- range $i, $testCase := $funcSpec.TestCases}}
 
-    t.Run("{{sanitizeName $testCase.CaseDescr}}", func(t *testing.T) {
+    t.Run({{quote (sanitizeName $testCase.CaseDescr)}}, func(t *testing.T) {
+{{- if $testCase.MockSetups}}
+        ctrl := gomock.NewController(t)
+{{- range $mock := $testCase.MockSetups}}
+        {{$mock.Name}} := {{$mock.Constructor}}(ctrl)
+{{- end}}
+{{- range $mock := $testCase.MockSetups}}
+{{- if $mock.Ordered}}
+        gomock.InOrder(
+{{- range $call := $mock.Calls}}
+            {{$call}},
+{{- end}}
+        )
+{{- else}}
+{{- range $call := $mock.Calls}}
+        {{$call}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{"\n"}}
+{{- end}}
+{{- if or $funcSpec.Router $funcSpec.Receiver}}
+{{- if $funcSpec.Router}}
+{{- if $funcSpec.RouterReturnsError}}
+        router, err := {{$funcSpec.Router}}()
+        if err != nil {
+            t.Fatalf("{{$funcSpec.Router}} returned an error: %v", err)
+        }
+{{- else}}
+        router := {{$funcSpec.Router}}()
+{{- end}}
+{{- else if $funcSpec.ConstructorReturnsError}}
+        receiver, err := {{$funcSpec.ReceiverExpr}}
+        if err != nil {
+            t.Fatalf("{{$funcSpec.Constructor}} returned an error: %v", err)
+        }
+{{- else}}
+        receiver := {{$funcSpec.ReceiverExpr}}
+{{- end}}
+{{end}}
         var reqReader io.Reader = nil
 {{- if hasBody $testCase.Request.Body}}
-{{- if hasRequestFields $testCase.RequestFields}}
+{{- if $testCase.RawRequest}}
+{{- if $testCase.Request.Raw}}
+        // The request body is declared raw and is sent as written in the spec.
+{{- else}}
+        // The request body does not fit {{$testCase.RequestType}} and is sent as written in the spec.
+{{- end}}
+        reqReader = bytes.NewReader([]byte({{rawString $testCase.Request.RawBody}}))
+{{- else if hasRequestFields $testCase.RequestFields}}
         requestData := {{$testCase.RequestType}}{
 {{- range $field := $testCase.RequestFields}}
             {{$field.FieldName}}: {{$field.ValueCode}},
@@ -36,17 +72,62 @@ func Test{{$funcSpec.Func}}(t *testing.T) {
         reqReader = bytes.NewReader([]byte(`{{jsonMarshal $testCase.Request.Body}}`))
 {{- end}}
 {{- end}}
-        req := httptest.NewRequestWithContext(ctx, "{{if $testCase.Request.Method}}{{$testCase.Request.Method}}{{else}}GET{{end}}", "{{if $testCase.Request.Path}}{{$testCase.Request.Path}}{{else}}/{{end}}", reqReader)
+        req := httptest.NewRequestWithContext(ctx, "{{if $testCase.Request.Method}}{{$testCase.Request.Method}}{{else}}GET{{end}}", {{if $testCase.Request.URL}}{{quote $testCase.Request.URL}}{{else}}"/"{{end}}, reqReader)
+{{- if not $funcSpec.Router}}
+{{- range $pathValue := $testCase.Request.PathValues}}
+        req.SetPathValue({{quote $pathValue.Name}}, {{quote $pathValue.Value}})
+{{- end}}
+{{- end}}
+{{- range $name, $values := $testCase.Request.Headers}}
+{{- range $value := $values}}
+        req.Header.Add({{quote $name}}, {{quote $value}})
+{{- end}}
+{{- end}}
 
         rr := httptest.NewRecorder()
+{{- if $funcSpec.Router}}
+        router.ServeHTTP(rr, req)
+{{- else if $funcSpec.Receiver}}
+        receiver.{{$funcSpec.Method}}(rr, req)
+{{- else}}
         {{$funcSpec.Func}}(rr, req)
+{{- end}}
 
-        if status := rr.Code; status != {{$testCase.Response.StatusCode}} {
-           t.Errorf("{{$funcSpec.Func}} returned wrong status code: got %v want {{$testCase.Response.StatusCode}}", status)
+        if status := rr.Code; status != {{$testCase.Response.StatusCode.Constant}} {
+           t.Errorf("{{$funcSpec.Func}} returned wrong status code: got %v want %v", status, {{$testCase.Response.StatusCode.Constant}})
         }
+{{- if $testCase.Response.Headers}}
+
+        // Result returns the headers as they were when the status code was written.
+        headers := rr.Result().Header
+{{- range $name, $values := $testCase.Response.Headers}}
+{{- if $values}}
+        if got, want := headers.Values({{quote $name}}), {{stringSlice $values}}; !slices.Equal(got, want) {
+            t.Errorf("{{$funcSpec.Func}} returned wrong %s header: got %q want %q", {{quote $name}}, got, want)
+        }
+{{- else}}
+        if got := headers.Values({{quote $name}}); len(got) > 0 {
+            t.Errorf("{{$funcSpec.Func}} returned unexpected %s header: got %q want none", {{quote $name}}, got)
+        }
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if $testCase.Response.Golden}}
+
+        assertGolden(t, {{quote $testCase.Response.Golden}}, rr.Body.Bytes())
+{{- end}}
 
 {{- if hasBody $testCase.Response.Body}}
-{{- if hasResponseFields $testCase.ResponseFields}}
+{{- if $testCase.BodyMatch}}
+
+        problems, err := matchJSON(rr.Body.Bytes(), {{rawString (jsonMarshal $testCase.Response.Body)}}, {{$testCase.BodyMatch}})
+        if err != nil {
+            t.Fatalf("Failed to unmarshal actual response: %v", err)
+        }
+        if len(problems) > 0 {
+            t.Errorf("{{$funcSpec.Func}} returned unexpected body:\n%s", strings.Join(problems, "\n"))
+        }
+{{- else if hasResponseFields $testCase.ResponseFields}}
 
         expectedResponse := {{$testCase.ResponseType}}{
 {{- range $field := $testCase.ResponseFields}}
@@ -58,35 +139,34 @@ func Test{{$funcSpec.Func}}(t *testing.T) {
         if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
             t.Fatalf("Failed to unmarshal actual response: %v", err)
         }
-
-{{- range $field := $testCase.ResponseFields}}
+{{range $field := $testCase.ResponseFields}}
 {{- if eq $field.GoType "string"}}
         if expectedResponse.{{$field.FieldName}} != actualResponse.{{$field.FieldName}} {
             t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %q\nwant: %q", actualResponse.{{$field.FieldName}}, expectedResponse.{{$field.FieldName}})
         }
-{{- else if eq $field.GoType "int"}}
+{{- else if or (eq $field.GoType "int") (eq $field.GoType "uint")}}
         if expectedResponse.{{$field.FieldName}} != actualResponse.{{$field.FieldName}} {
             t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %d\nwant: %d", actualResponse.{{$field.FieldName}}, expectedResponse.{{$field.FieldName}})
         }
 {{- else if eq $field.GoType "float"}}
         if expectedResponse.{{$field.FieldName}} != actualResponse.{{$field.FieldName}} {
-            t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %f\nwant: %f", actualResponse.{{$field.FieldName}}, expectedResponse.{{$field.FieldName}})
+            t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %v\nwant: %v", actualResponse.{{$field.FieldName}}, expectedResponse.{{$field.FieldName}})
         }
 {{- else if eq $field.GoType "bool"}}
         if expectedResponse.{{$field.FieldName}} != actualResponse.{{$field.FieldName}} {
             t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %t\nwant: %t", actualResponse.{{$field.FieldName}}, expectedResponse.{{$field.FieldName}})
         }
 {{- else if eq $field.GoType "struct"}}
-        expectedFieldJSON, _ := json.Marshal(expectedResponse.{{$field.FieldName}})
-        actualFieldJSON, _ := json.Marshal(actualResponse.{{$field.FieldName}})
-        if string(expectedFieldJSON) != string(actualFieldJSON) {
-            t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %s\nwant: %s", string(actualFieldJSON), string(expectedFieldJSON))
+        expected{{$field.FieldName}}JSON, _ := json.Marshal(expectedResponse.{{$field.FieldName}})
+        actual{{$field.FieldName}}JSON, _ := json.Marshal(actualResponse.{{$field.FieldName}})
+        if string(expected{{$field.FieldName}}JSON) != string(actual{{$field.FieldName}}JSON) {
+            t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %s\nwant: %s", string(actual{{$field.FieldName}}JSON), string(expected{{$field.FieldName}}JSON))
         }
 {{- else}}
-        expectedFieldJSON, _ := json.Marshal(expectedResponse.{{$field.FieldName}})
-        actualFieldJSON, _ := json.Marshal(actualResponse.{{$field.FieldName}})
-        if string(expectedFieldJSON) != string(actualFieldJSON) {
-            t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %s\nwant: %s", string(actualFieldJSON), string(expectedFieldJSON))
+        expected{{$field.FieldName}}JSON, _ := json.Marshal(expectedResponse.{{$field.FieldName}})
+        actual{{$field.FieldName}}JSON, _ := json.Marshal(actualResponse.{{$field.FieldName}})
+        if string(expected{{$field.FieldName}}JSON) != string(actual{{$field.FieldName}}JSON) {
+            t.Errorf("{{$funcSpec.Func}} field {{$field.FieldName}} mismatch:\ngot:  %s\nwant: %s", string(actual{{$field.FieldName}}JSON), string(expected{{$field.FieldName}}JSON))
         }
 {{- end}}
 {{- end}}
@@ -116,9 +196,422 @@ func Test{{$funcSpec.Func}}(t *testing.T) {
            t.Errorf("{{$funcSpec.Func}} returned unexpected body:\ngot:  %s\nwant: %s", string(actualJSON), string(expectedJSON))
         }
 {{- end}}
+{{- end}}
+{{- if $testCase.Assertions}}
+
+        for _, problem := range assertJSON(rr.Body.Bytes(), []jsonAssertion{
+{{- range $assertion := $testCase.Assertions}}
+            {{$assertion}},
+{{- end}}
+        }) {
+            t.Errorf("{{$funcSpec.Func}} returned unexpected body: %s", problem)
+        }
 {{- end}}
     })
 {{- end}}
 }
 
-{{- end}}
\ No newline at end of file
+{{- end}}
+{{- if .UsesAddressOf}}
+
+// addressOf returns a pointer to a copy of v.
+func addressOf[T any](v T) *T {
+    return &v
+}
+{{- end}}
+{{- if .UsesBodyMatch}}
+
+// jsonMatch relaxes the comparison of a JSON body with the expected one.
+// Subset allows objects to hold keys the expected ones leave out, Ignore lists the paths whose values are not compared
+// and Unordered the paths of the arrays whose items may be in any order. In paths, * matches any key and [*] any index.
+type jsonMatch struct {
+    Subset    bool
+    Ignore    []string
+    Unordered []string
+}
+
+// matchJSON compares a JSON body with the expected one and returns the differences, prefixed by their path.
+// Expected values can be matchers: {"$any": true} matches any value, {"$regex": "expr"} strings matching a regular expression,
+// {"$type": "number"} values of a JSON type and {"$rfc3339": true} RFC 3339 timestamps.
+func matchJSON(body []byte, expected string, match jsonMatch) ([]string, error) {
+    want, err := decodeJSON([]byte(expected))
+    if err != nil {
+        return nil, fmt.Errorf("invalid expected body: %w", err)
+    }
+    got, err := decodeJSON(body)
+    if err != nil {
+        return nil, err
+    }
+
+    m := jsonMatcher{subset: match.Subset}
+    for _, path := range match.Ignore {
+        m.ignore = append(m.ignore, jsonPathRegexp(path))
+    }
+    for _, path := range match.Unordered {
+        m.unordered = append(m.unordered, jsonPathRegexp(path))
+    }
+    return m.compare("$", want, got), nil
+}
+
+// jsonMatcher compares JSON values with expected ones, see matchJSON
+type jsonMatcher struct {
+    subset    bool
+    ignore    []*regexp.Regexp
+    unordered []*regexp.Regexp
+}
+
+func (m jsonMatcher) compare(path string, want, got any) []string {
+    if matchesJSONPath(m.ignore, path) {
+        return nil
+    }
+
+    if obj, ok := want.(map[string]any); ok && len(obj) == 1 {
+        for matcher, arg := range obj {
+            switch matcher {
+            case "$any":
+                return nil
+            case "$regex":
+                if s, ok := got.(string); ok && regexp.MustCompile(arg.(string)).MatchString(s) {
+                    return nil
+                }
+                return []string{fmt.Sprintf("%s: got %s, want a string matching %q", path, jsonText(got), arg)}
+            case "$type":
+                if jsonType(got) == arg {
+                    return nil
+                }
+                return []string{fmt.Sprintf("%s: got %s, want a %s", path, jsonText(got), arg)}
+            case "$rfc3339":
+                if s, ok := got.(string); ok {
+                    if _, err := time.Parse(time.RFC3339, s); err == nil {
+                        return nil
+                    }
+                }
+                return []string{fmt.Sprintf("%s: got %s, want an RFC 3339 timestamp", path, jsonText(got))}
+            }
+        }
+    }
+
+    switch want := want.(type) {
+    case map[string]any:
+        obj, ok := got.(map[string]any)
+        if !ok {
+            return []string{fmt.Sprintf("%s: got %s, want an object", path, jsonText(got))}
+        }
+
+        var problems []string
+        for _, key := range slices.Sorted(maps.Keys(want)) {
+            value, ok := obj[key]
+            if !ok && !matchesJSONPath(m.ignore, path+"."+key) {
+                problems = append(problems, fmt.Sprintf("%s.%s: missing, want %s", path, key, jsonText(want[key])))
+                continue
+            }
+            problems = append(problems, m.compare(path+"."+key, want[key], value)...)
+        }
+        for _, key := range slices.Sorted(maps.Keys(obj)) {
+            if _, ok := want[key]; !ok && !m.subset && !matchesJSONPath(m.ignore, path+"."+key) {
+                problems = append(problems, fmt.Sprintf("%s.%s: got unexpected %s", path, key, jsonText(obj[key])))
+            }
+        }
+        return problems
+    case []any:
+        items, ok := got.([]any)
+        if !ok || len(items) != len(want) {
+            return []string{fmt.Sprintf("%s: got %s, want %d item(s)", path, jsonText(got), len(want))}
+        }
+
+        var problems []string
+        if !matchesJSONPath(m.unordered, path) {
+            for i := range want {
+                problems = append(problems, m.compare(path+"["+strconv.Itoa(i)+"]", want[i], items[i])...)
+            }
+            return problems
+        }
+
+        // Items can match several expected items, e.g. through matchers, so expected items are assigned
+        // distinct items along augmenting paths, reassigning the items already taken when needed
+        matches := make([][]bool, len(want))
+        for i, wantItem := range want {
+            matches[i] = make([]bool, len(items))
+            for j, item := range items {
+                matches[i][j] = len(m.compare(path+"["+strconv.Itoa(j)+"]", wantItem, item)) == 0
+            }
+        }
+
+        assigned := make([]int, len(items))
+        for j := range assigned {
+            assigned[j] = -1
+        }
+        var assign func(i int, visited []bool) bool
+        assign = func(i int, visited []bool) bool {
+            for j := range items {
+                if matches[i][j] && !visited[j] {
+                    visited[j] = true
+                    if assigned[j] == -1 || assign(assigned[j], visited) {
+                        assigned[j] = i
+                        return true
+                    }
+                }
+            }
+            return false
+        }
+
+        for i, wantItem := range want {
+            if !assign(i, make([]bool, len(items))) {
+                problems = append(problems, fmt.Sprintf("%s: no item matches %s", path, jsonText(wantItem)))
+            }
+        }
+        return problems
+    case json.Number:
+        if n, ok := got.(json.Number); !ok || compareJSONNumbers(n, want) != 0 {
+            return []string{fmt.Sprintf("%s: got %s, want %s", path, jsonText(got), jsonText(want))}
+        }
+        return nil
+    default:
+        if want != got {
+            return []string{fmt.Sprintf("%s: got %s, want %s", path, jsonText(got), jsonText(want))}
+        }
+        return nil
+    }
+}
+
+// jsonPathRegexp compiles a JSON path such as $.items[*].id into a regular expression matching the paths it designates
+func jsonPathRegexp(path string) *regexp.Regexp {
+    expr := regexp.QuoteMeta(path)
+    expr = strings.ReplaceAll(expr, `\[\*\]`, `\[[0-9]+\]`)
+    expr = strings.ReplaceAll(expr, `\.\*`, `\.[^.\[]+`)
+    return regexp.MustCompile("^" + expr + "$")
+}
+
+// matchesJSONPath reports whether a path matches any of the compiled JSON paths
+func matchesJSONPath(paths []*regexp.Regexp, path string) bool {
+    return slices.ContainsFunc(paths, func(re *regexp.Regexp) bool { return re.MatchString(path) })
+}
+
+// jsonType returns the JSON type of a decoded value
+func jsonType(v any) string {
+    switch v.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "boolean"
+    case json.Number:
+        return "number"
+    case string:
+        return "string"
+    case []any:
+        return "array"
+    default:
+        return "object"
+    }
+}
+{{- end}}
+{{- if .UsesAssertions}}
+
+// jsonAssertion checks the value at a JSON path of a body, such as $.items[0].id, with an operator:
+// ==, !=, >, >=, <, <=, contains, length or exists. Value is the JSON encoded operand.
+type jsonAssertion struct {
+    Path  string
+    Op    string
+    Value string
+}
+
+// assertJSON checks assertions on a JSON body and returns the failed ones, with their path and the expected and actual values.
+func assertJSON(body []byte, assertions []jsonAssertion) []string {
+    doc, err := decodeJSON(body)
+    if err != nil {
+        return []string{fmt.Sprintf("could not unmarshal %q: %v", body, err)}
+    }
+
+    var problems []string
+    for _, assertion := range assertions {
+        want, err := decodeJSON([]byte(assertion.Value))
+        if err != nil {
+            problems = append(problems, fmt.Sprintf("%s: invalid value %s: %v", assertion.Path, assertion.Value, err))
+            continue
+        }
+
+        got, found := jsonPathValue(doc, assertion.Path)
+        switch {
+        case assertion.Op == "exists":
+            if found != want {
+                problems = append(problems, fmt.Sprintf("%s: got exists %t, want exists %s", assertion.Path, found, assertion.Value))
+            }
+        case !found:
+            problems = append(problems, fmt.Sprintf("%s: missing, want %s %s", assertion.Path, assertion.Op, assertion.Value))
+        default:
+            if actual, ok := checkJSONAssertion(assertion.Op, got, want); !ok {
+                problems = append(problems, fmt.Sprintf("%s: got %s, want %s %s", assertion.Path, actual, assertion.Op, assertion.Value))
+            }
+        }
+    }
+    return problems
+}
+
+// checkJSONAssertion applies the operator of an assertion to a value, returning the value as it is described on failure
+func checkJSONAssertion(op string, got, want any) (string, bool) {
+    switch op {
+    case "==":
+        return jsonText(got), equalJSON(got, want)
+    case "!=":
+        return jsonText(got), !equalJSON(got, want)
+    case ">", ">=", "<", "<=":
+        g, isNumber := got.(json.Number)
+        w, _ := want.(json.Number)
+        if !isNumber {
+            return jsonText(got), false
+        }
+        cmp := compareJSONNumbers(g, w)
+        return jsonText(got), op == ">" && cmp > 0 || op == ">=" && cmp >= 0 || op == "<" && cmp < 0 || op == "<=" && cmp <= 0
+    case "contains":
+        switch got := got.(type) {
+        case string:
+            s, ok := want.(string)
+            return jsonText(got), ok && strings.Contains(got, s)
+        case []any:
+            return jsonText(got), slices.ContainsFunc(got, func(item any) bool { return equalJSON(item, want) })
+        case map[string]any:
+            key, ok := want.(string)
+            _, found := got[key]
+            return jsonText(got), ok && found
+        }
+    case "length":
+        var n int
+        switch got := got.(type) {
+        case string:
+            n = len([]rune(got))
+        case []any:
+            n = len(got)
+        case map[string]any:
+            n = len(got)
+        default:
+            return jsonText(got), false
+        }
+        w, _ := want.(json.Number)
+        return fmt.Sprintf("length %d", n), w == json.Number(strconv.Itoa(n))
+    }
+    return jsonText(got), false
+}
+
+// equalJSON reports whether two decoded JSON values are equal, comparing numbers by value
+func equalJSON(a, b any) bool {
+    switch a := a.(type) {
+    case json.Number:
+        b, ok := b.(json.Number)
+        return ok && compareJSONNumbers(a, b) == 0
+    case []any:
+        b, ok := b.([]any)
+        return ok && slices.EqualFunc(a, b, equalJSON)
+    case map[string]any:
+        b, ok := b.(map[string]any)
+        return ok && maps.EqualFunc(a, b, equalJSON)
+    default:
+        return a == b
+    }
+}
+
+// jsonPathValue returns the value at a JSON path of keys and indexes, such as $.items[0].id, and whether it exists
+func jsonPathValue(value any, path string) (any, bool) {
+    rest := strings.TrimPrefix(path, "$")
+    for rest != "" {
+        if rest[0] == '[' {
+            end := strings.IndexByte(rest, ']')
+            i, err := strconv.Atoi(rest[1:end])
+            items, ok := value.([]any)
+            if err != nil || !ok || i >= len(items) {
+                return nil, false
+            }
+            value, rest = items[i], rest[end+1:]
+            continue
+        }
+
+        end := strings.IndexAny(rest[1:], ".[") + 1
+        if end == 0 {
+            end = len(rest)
+        }
+        obj, ok := value.(map[string]any)
+        if !ok {
+            return nil, false
+        }
+        if value, ok = obj[rest[1:end]]; !ok {
+            return nil, false
+        }
+        rest = rest[end:]
+    }
+    return value, true
+}
+{{- end}}
+{{- if or .UsesBodyMatch .UsesAssertions}}
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Number so that large integers are compared exactly
+func decodeJSON(data []byte) (any, error) {
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.UseNumber()
+
+    var v any
+    if err := decoder.Decode(&v); err != nil {
+        return nil, err
+    }
+    if _, err := decoder.Token(); err != io.EOF {
+        return nil, errors.New("invalid data after the JSON value")
+    }
+    return v, nil
+}
+
+// compareJSONNumbers compares two decoded JSON numbers, returning -1, 0 or +1 as big.Rat.Cmp does
+func compareJSONNumbers(a, b json.Number) int {
+    x, _ := new(big.Rat).SetString(a.String())
+    y, _ := new(big.Rat).SetString(b.String())
+    return x.Cmp(y)
+}
+
+// jsonText returns the JSON encoding of a decoded value
+func jsonText(v any) string {
+    b, _ := json.Marshal(v)
+    return string(b)
+}
+{{- end}}
+{{- if .UsesGolden}}
+
+// init registers the -update flag, rewriting the golden files with the bodies returned by the handlers.
+// Packages declaring their own -update flag at package level, as in the standard golden file idiom, share it:
+// package level variables are initialized before init functions run.
+func init() {
+    if flag.Lookup("update") == nil {
+        flag.Bool("update", false, "update the golden files of the tests")
+    }
+}
+
+// assertGolden compares a response body with the golden file at path, writing the file first when -update is set.
+// JSON bodies are stored indented and compared compacted.
+func assertGolden(t *testing.T, path string, body []byte) {
+    t.Helper()
+
+    if flag.Lookup("update").Value.String() == "true" {
+        content := body
+        var indented bytes.Buffer
+        // json.Encoder terminates bodies with a newline, trimmed so that golden files end with a single one
+        if err := json.Indent(&indented, bytes.TrimRight(body, " \t\r\n"), "", "  "); err == nil {
+            content = append(indented.Bytes(), '\n')
+        }
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            t.Fatalf("could not create the directory of %s: %v", path, err)
+        }
+        if err := os.WriteFile(path, content, 0o644); err != nil {
+            t.Fatalf("could not update %s: %v", path, err)
+        }
+    }
+
+    want, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("could not read golden file, run the tests with -update to create it: %v", err)
+    }
+
+    var got, expected bytes.Buffer
+    if json.Compact(&got, body) == nil && json.Compact(&expected, want) == nil {
+        body, want = got.Bytes(), expected.Bytes()
+    }
+    if !bytes.Equal(body, want) {
+        t.Errorf("response body does not match %s, run the tests with -update to accept it:\ngot:  %s\nwant: %s", path, body, want)
+    }
+}
+{{- end}}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var (
+	//go:embed testcases.schema.json
+	testCasesSchema []byte
+
+	// specSchema is the compiled JSON Schema of specs
+	specSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(testCasesSchema))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse schema: %w", err)
+		}
+
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource("testcases.schema.json", doc); err != nil {
+			return nil, fmt.Errorf("could not add schema: %w", err)
+		}
+		return compiler.Compile("testcases.schema.json")
+	})
+
+	// schemaPrinter formats schema validation errors
+	schemaPrinter = message.NewPrinter(language.English)
+)
+
+// diagnostic is a problem found in a spec
+type diagnostic struct {
+	Pos position
+	Msg string
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+// diagnosticsError reports the problems found in a spec, one per line
+type diagnosticsError []diagnostic
+
+func (e diagnosticsError) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, d := range e {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateSpec checks that a spec is valid JSON or YAML matching the spec schema and that its test cases can be decoded.
+// It returns the problems found, sorted by position, and the index locating the values of the spec.
+func validateSpec(file string, data []byte) ([]diagnostic, *specIndex) {
+	idx, err := indexSpec(file, data)
+	if err != nil {
+		return []diagnostic{syntaxDiagnostic(file, data, err)}, nil
+	}
+
+	schema, err := specSchema()
+	if err != nil {
+		return []diagnostic{{Pos: idx.valuePos(nil), Msg: err.Error()}}, idx
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(idx.json))
+	if err != nil {
+		return []diagnostic{syntaxDiagnostic(file, idx.json, err)}, idx
+	}
+
+	var (
+		diags         []diagnostic
+		validationErr *jsonschema.ValidationError
+	)
+	if err := schema.Validate(instance); errors.As(err, &validationErr) {
+		diags = schemaDiagnostics(validationErr, nil, idx)
+	} else if err != nil {
+		diags = []diagnostic{{Pos: idx.valuePos(nil), Msg: err.Error()}}
+	}
+
+	// Functions and test cases are decoded one by one, to locate the problems the schema cannot express
+	if len(diags) == 0 {
+		var specs []json.RawMessage
+		if err := json.Unmarshal(idx.json, &specs); err != nil {
+			return []diagnostic{{Pos: idx.valuePos(nil), Msg: err.Error()}}, idx
+		}
+		for i, rawSpec := range specs {
+			var spec struct {
+				FunctionTestSpec
+				RawCases []json.RawMessage `json:"test-cases"`
+			}
+			specPath := []string{strconv.Itoa(i)}
+			if err := json.Unmarshal(rawSpec, &spec); err != nil {
+				diags = append(diags, decodeDiagnostic(err, specPath, idx))
+				continue
+			}
+
+			for j, rawCase := range spec.RawCases {
+				var testCase TestCase
+				if err := json.Unmarshal(rawCase, &testCase); err != nil {
+					diags = append(diags, decodeDiagnostic(err, append(specPath, "test-cases", strconv.Itoa(j)), idx))
+				}
+			}
+		}
+	}
+
+	return sortDiagnostics(diags), idx
+}
+
+// schemaDiagnostics converts the errors of a schema validation to diagnostics positioned at the offending values
+func schemaDiagnostics(err *jsonschema.ValidationError, parentPath []string, idx *specIndex) []diagnostic {
+	// Errors of property names are not located at the object they belong to, nor at the property holding it,
+	// so they are reported at the closest key with the invalid name
+	path := err.InstanceLocation
+	if len(path) < len(parentPath) {
+		path = parentPath
+	}
+
+	switch k := err.ErrorKind.(type) {
+	case *kind.AdditionalProperties:
+		var diags []diagnostic
+		for _, property := range k.Properties {
+			diags = append(diags, diagnostic{
+				Pos: idx.keyPos(append(path[:len(path):len(path)], property)),
+				Msg: fmt.Sprintf("unknown property %q", property),
+			})
+		}
+		return diags
+	case *kind.PropertyNames:
+		return []diagnostic{{
+			Pos: idx.findKeyPos(path, k.Property),
+			Msg: fmt.Sprintf("invalid name %q", k.Property),
+		}}
+	case *kind.OneOf, *kind.AnyOf:
+		// Alternatives differing only by type are reported as a single type error
+		if got, want, ok := typeAlternatives(err); ok {
+			return []diagnostic{{
+				Pos: idx.valuePos(path),
+				Msg: fmt.Sprintf("got %s, want %s", got, strings.Join(want, " or ")),
+			}}
+		}
+
+		// Otherwise only the alternatives matching the type of the value are relevant
+		var diags []diagnostic
+		for _, cause := range err.Causes {
+			if _, _, ok := typeAlternatives(cause); !ok || !slices.Equal(cause.InstanceLocation, err.InstanceLocation) {
+				diags = append(diags, schemaDiagnostics(cause, path, idx)...)
+			}
+		}
+		return diags
+	}
+
+	if len(err.Causes) == 0 {
+		return []diagnostic{{Pos: idx.valuePos(path), Msg: err.ErrorKind.LocalizedString(schemaPrinter)}}
+	}
+
+	var diags []diagnostic
+	for _, cause := range err.Causes {
+		diags = append(diags, schemaDiagnostics(cause, path, idx)...)
+	}
+	return diags
+}
+
+// decodeDiagnostic positions an error decoding the function or test case at path,
+// at the offending status code when it is unknown
+func decodeDiagnostic(err error, path []string, idx *specIndex) diagnostic {
+	var statusErr *statusCodeError
+	if !errors.As(err, &statusErr) {
+		return diagnostic{Pos: idx.valuePos(path), Msg: err.Error()}
+	}
+
+	// Status codes are either the status code of a response or a key of the response types
+	pos := idx.findKeyPos(append(path[:len(path):len(path)], "response_types"), statusErr.Value)
+	if len(path) > 1 {
+		pos = idx.valuePos(append(path[:len(path):len(path)], "response", "status_code"))
+	}
+	return diagnostic{Pos: pos, Msg: statusErr.Error()}
+}
+
+// typeAlternatives returns the type of a value and the types it could have had,
+// when all the alternatives of a validation error failed because of the type of the value
+func typeAlternatives(err *jsonschema.ValidationError) (string, []string, bool) {
+	var (
+		got  string
+		want []string
+		walk func(e *jsonschema.ValidationError) bool
+	)
+	walk = func(e *jsonschema.ValidationError) bool {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				if !walk(cause) {
+					return false
+				}
+			}
+			return true
+		}
+
+		typeErr, ok := e.ErrorKind.(*kind.Type)
+		if !ok || !slices.Equal(e.InstanceLocation, err.InstanceLocation) {
+			return false
+		}
+		got = typeErr.Got
+		for _, w := range typeErr.Want {
+			if !slices.Contains(want, w) {
+				want = append(want, w)
+			}
+		}
+		return true
+	}
+
+	if !walk(err) || got == "" {
+		return "", nil, false
+	}
+	return got, want, true
+}
+
+// sortDiagnostics sorts diagnostics by position, removing duplicates
+func sortDiagnostics(diags []diagnostic) []diagnostic {
+	slices.SortStableFunc(diags, func(a, b diagnostic) int {
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+		return a.Pos.Col - b.Pos.Col
+	})
+	return slices.Compact(diags)
+}
+
+// validateHandlers checks that the functions under test are declared in the package with a signature
+// compatible with http.HandlerFunc, or that the router or constructor they declare builds what they need, see resolveHandler.
+// The mocks of the test cases are checked against the resolved handlers, see checkMocks.
+func validateHandlers(specs []FunctionTestSpec, pkgInfo PackageInfo, idx *specIndex) []diagnostic {
+	var diags []diagnostic
+	for i := range specs {
+		var specErr *specError
+		if err := resolveHandler(&specs[i], pkgInfo); errors.As(err, &specErr) {
+			diags = append(diags, diagnostic{
+				Pos: idx.valuePos([]string{strconv.Itoa(i), specErr.Property}),
+				Msg: specErr.Msg,
+			})
+			continue
+		}
+
+		for _, mockErr := range checkMocks(specs[i], pkgInfo.Imports) {
+			path := []string{strconv.Itoa(i), "test-cases", strconv.Itoa(mockErr.Case), "mocks"}
+			if mockErr.Mock >= 0 {
+				path = append(path, strconv.Itoa(mockErr.Mock), "name")
+			}
+			diags = append(diags, diagnostic{Pos: idx.valuePos(path), Msg: mockErr.Msg})
+		}
+	}
+	return diags
+}
+
+// validateBodyFiles checks that the bodies read from files exist and are objects, relative to the package directory dir
+func validateBodyFiles(specs []FunctionTestSpec, dir string, idx *specIndex) []diagnostic {
+	var (
+		diags   []diagnostic
+		joinErr interface{ Unwrap() []error }
+	)
+	if err := loadBodyFiles(specs, dir); errors.As(err, &joinErr) {
+		for _, err := range joinErr.Unwrap() {
+			var fileErr *bodyFileError
+			if errors.As(err, &fileErr) {
+				diags = append(diags, diagnostic{Pos: idx.valuePos(fileErr.Path), Msg: fileErr.Err.Error()})
+			}
+		}
+	}
+	return diags
+}
+
+// runValidate validates a spec and, when an input package is given, the handlers it tests.
+// Every problem found is printed to w, positioned in the spec.
+func runValidate(args []string, w io.Writer) error {
+	var (
+		flags         = flag.NewFlagSet("validate", flag.ContinueOnError)
+		testCasesFile = flags.String("testcases", "", "JSON or YAML file containing test cases")
+		input         = flags.String("input", "", "Input Go file or package directory declaring the handlers under test (optional)")
+		router        = flags.String("router", "", "Function constructing the http.Handler serving the requests of functions not declaring one (optional)")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *testCasesFile == "" {
+		return errors.New("test cases file is required")
+	}
+
+	data, err := os.ReadFile(*testCasesFile)
+	if err != nil {
+		return fmt.Errorf("could not read test cases: %w", err)
+	}
+
+	diags, idx := validateSpec(*testCasesFile, data)
+	if len(diags) == 0 && *input != "" {
+		pkgInfo, err := loadPackage(*input)
+		if err != nil {
+			return fmt.Errorf("could not load input %s: %w", *input, err)
+		}
+
+		var specs []FunctionTestSpec
+		if err := json.Unmarshal(idx.json, &specs); err != nil {
+			return fmt.Errorf("could not decode test cases: %w", err)
+		}
+		for i := range specs {
+			if specs[i].Router == "" && specs[i].Constructor == "" {
+				specs[i].Router = *router
+			}
+		}
+		diags = validateHandlers(specs, pkgInfo, idx)
+		diags = append(diags, validateBodyFiles(specs, pkgInfo.Dir, idx)...)
+		diags = sortDiagnostics(diags)
+	}
+
+	for _, d := range diags {
+		fmt.Fprintln(w, d)
+	}
+	if len(diags) > 0 {
+		return fmt.Errorf("found %d problem(s) in %s", len(diags), *testCasesFile)
+	}
+
+	fmt.Fprintf(w, "%s is valid\n", *testCasesFile)
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestValidateSpec(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		spec     string
+		expected []string
+	}{
+		{
+			name: "valid specs",
+			spec: `[{"func": "CreateUserHandler", "test-cases": [{
+				"case_descr": "it should succeed",
+				"request": {"method": "POST", "path": "/users", "body": {"name": "Andrea"}, "headers": {"Accept": ["a", "b"]}},
+				"response": {"status_code": "201", "headers": {"X-Debug": null}}
+			}]}]`,
+		},
+		{
+			name: "misspelled properties",
+			spec: `[
+  {"func": "CreateUserHandler", "test_cases": []}
+]`,
+			expected: []string{
+				`spec.json:2:3: missing property 'test-cases'`,
+				`spec.json:2:33: unknown property "test_cases"`,
+			},
+		},
+		{
+			name: "values of the wrong type",
+			spec: `[{"func": "H", "test-cases": [{"case_descr": "a",
+"response": {"status_code": true, "headers": {"X-A": 1, "X-B": [1]}}}]}]`,
+			expected: []string{
+				`spec.json:2:29: got boolean, want integer or string`,
+				`spec.json:2:54: got number, want string or array or null`,
+				`spec.json:2:65: got number, want string`,
+			},
+		},
+		{
+			name:     "invalid header names",
+			spec:     `[{"func": "H", "test-cases": [{"case_descr": "a", "request": {"headers": {"X A": "b"}}, "response": {"status_code": "200"}}]}]`,
+			expected: []string{`spec.json:1:75: invalid name "X A"`},
+		},
+		{
+			name: "unknown status codes",
+			spec: `[
+  {"func": "H", "response_types": {"200": "A", "Teapots": "B"}, "test-cases": []},
+  {"func": "H", "test-cases": [{"case_descr": "a", "response": {"status_code": 299}}]}
+]`,
+			expected: []string{
+				`spec.json:2:48: unknown status code "Teapots"`,
+				`spec.json:3:80: unknown status code "299"`,
+			},
+		},
+		{
+			name:     "duplicate headers",
+			spec:     `[{"func": "H", "test-cases": [{"case_descr": "a", "request": {"headers": {"x-a": "b", "X-A": "c"}}, "response": {"status_code": "200"}}]}]`,
+			expected: []string{`spec.json:1:31: duplicate header "X-A"`},
+		},
+		{
+			name:     "malformed JSON",
+			spec:     "[\n  {\"func\": \"H\",}\n]",
+			expected: []string{`spec.json:2:15: invalid character ',' looking for beginning of value`},
+		},
+	} {
+		t.Run("it should report "+tt.name, func(t *testing.T) {
+			diags, _ := validateSpec("spec.json", []byte(tt.spec))
+
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.String())
+			}
+			if !slices.Equal(tt.expected, got) {
+				t.Fatalf("unexpected diagnostics:\ngot:  %q\nwant: %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateHandlers(t *testing.T) {
+	t.Run("it should report functions that are missing or are not handlers", func(t *testing.T) {
+		spec := `[
+  {"func": "PingHandler", "test-cases": []},
+  {"func": "NewRouter", "test-cases": []},
+  {"func": "PongHandler", "test-cases": []}
+]`
+		diags, idx := validateSpec("spec.json", []byte(spec))
+		if len(diags) > 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		pkgInfo := PackageInfo{
+			Name: "handler",
+			Funcs: map[string]FuncInfo{
+				"PingHandler": {Signature: "func(w net/http.ResponseWriter, r *net/http.Request)", Handler: true},
+				"NewRouter":   {Signature: "func() *net/http.ServeMux"},
+			},
+		}
+		specs := []FunctionTestSpec{{Func: "PingHandler"}, {Func: "NewRouter"}, {Func: "PongHandler"}}
+
+		var got []string
+		for _, d := range validateHandlers(specs, pkgInfo, idx) {
+			got = append(got, d.String())
+		}
+
+		expected := []string{
+			`spec.json:3:12: NewRouter has signature func() *net/http.ServeMux, which is not compatible with http.HandlerFunc`,
+			`spec.json:4:12: PongHandler is not declared in package handler`,
+		}
+		if !slices.Equal(expected, got) {
+			t.Fatalf("unexpected diagnostics:\ngot:  %q\nwant: %q", got, expected)
+		}
+	})
+	t.Run("it should report routers that are missing or do not construct handlers", func(t *testing.T) {
+		const src = `package routes
+
+import "net/http"
+
+func NewMux() *http.ServeMux { return http.NewServeMux() }
+
+func NewHandler() (http.Handler, error) { return http.NewServeMux(), nil }
+
+func NewRoutes(prefix string) http.Handler { return http.NewServeMux() }
+
+func NewName() (string, error) { return "", nil }
+`
+		info, _, _, pkg := loadTestPackage(t, src)
+		info.Funcs = collectFuncs(pkg, nil)
+
+		spec := `[
+  {"func": "Mux", "router": "NewMux", "test-cases": []},
+  {"func": "Handler", "router": "NewHandler", "test-cases": []},
+  {"func": "Routes", "router": "NewRoutes", "test-cases": []},
+  {"func": "Name", "router": "NewName", "test-cases": []},
+  {"func": "Missing", "router": "NewMissing", "test-cases": []}
+]`
+		diags, idx := validateSpec("spec.json", []byte(spec))
+		if len(diags) > 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		var specs []FunctionTestSpec
+		if err := json.Unmarshal(idx.json, &specs); err != nil {
+			t.Fatalf("could not decode specs: %v", err)
+		}
+
+		var got []string
+		for _, d := range validateHandlers(specs, info, idx) {
+			got = append(got, d.String())
+		}
+
+		expected := []string{
+			`spec.json:4:32: NewRoutes has signature func(prefix string) net/http.Handler, which does not return an http.Handler`,
+			`spec.json:5:30: NewName has signature func() (string, error), which does not return an http.Handler`,
+			`spec.json:6:33: NewMissing is not declared in package routes`,
+		}
+		if !slices.Equal(expected, got) {
+			t.Fatalf("unexpected diagnostics:\ngot:  %q\nwant: %q", got, expected)
+		}
+		if !info.Funcs["NewHandler"].ReturnsError || info.Funcs["NewMux"].ReturnsError {
+			t.Fatalf("unexpected error results: %+v", info.Funcs)
+		}
+	})
+	t.Run("it should report mocks that do not reach the handler under test", func(t *testing.T) {
+		info, _, _, pkg := loadTestPackage(t, receiversSrc)
+		info.Funcs = collectFuncs(pkg, packageQualifier(pkg))
+
+		spec := `[
+  {"func": "Health", "test-cases": [
+    {"case_descr": "function", "response": {"status_code": 200}, "mocks": [{"name": "store", "interface": "Store"}]}
+  ]},
+  {"func": "Server.GetUser", "constructor": "NewServer", "test-cases": [
+    {"case_descr": "package constructor", "response": {"status_code": 200}, "mocks": [{"name": "store", "interface": "Store"}]}
+  ]},
+  {"func": "Server.GetUser", "constructor": "&Server{name: cfg.store}", "test-cases": [
+    {"case_descr": "without mocks", "response": {"status_code": 200}},
+    {"case_descr": "expression", "response": {"status_code": 200}, "mocks": [
+      {"name": "cfg", "interface": "Config"},
+      {"name": "store", "interface": "Store"},
+      {"name": "rr", "interface": "Store"}
+    ]}
+  ]}
+]`
+		diags, idx := validateSpec("spec.json", []byte(spec))
+		if len(diags) > 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		var specs []FunctionTestSpec
+		if err := json.Unmarshal(idx.json, &specs); err != nil {
+			t.Fatalf("could not decode specs: %v", err)
+		}
+
+		var got []string
+		for _, d := range validateHandlers(specs, info, idx) {
+			got = append(got, d.String())
+		}
+
+		expected := []string{
+			`spec.json:3:75: Health is a function, which takes no mocks, test a method whose constructor expression passes them to the receiver`,
+			`spec.json:6:86: constructor NewServer takes no mocks, use a constructor expression passing them to the receiver`,
+			`spec.json:12:16: mock store: not used by constructor &Server{name: cfg.store}`,
+			`spec.json:13:16: mock rr: the name is used by the generated tests`,
+		}
+		if !slices.Equal(expected, got) {
+			t.Fatalf("unexpected diagnostics:\ngot:  %q\nwant: %q", got, expected)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// verifyTests type checks generated tests as if they were written to outputFile, together with the package
+// in the same directory, without touching the file system. Errors are reported with the function and test case
+// of the spec that generated the offending code.
+func verifyTests(spec GenerationSpec, outputFile string, src []byte) error {
+	outputPath, err := filepath.Abs(outputFile)
+	if err != nil {
+		return fmt.Errorf("could not resolve output %s: %w", outputFile, err)
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:    loadMode,
+		Dir:     filepath.Dir(outputPath),
+		Tests:   true,
+		Overlay: map[string][]byte{outputPath: src},
+	}, ".")
+	if err != nil {
+		return fmt.Errorf("could not load generated tests: %w", err)
+	}
+
+	// The generated tests belong to the test variant of the package, which compiles them
+	idx := slices.IndexFunc(pkgs, func(pkg *packages.Package) bool {
+		return slices.Contains(pkg.GoFiles, outputPath)
+	})
+	if idx < 0 {
+		return fmt.Errorf("could not find the package of %s", outputFile)
+	}
+
+	var (
+		lines = strings.Split(string(src), "\n")
+		errs  []error
+	)
+	for _, pkgErr := range pkgs[idx].Errors {
+		file, line, col := splitErrorPos(pkgErr.Pos)
+		if file != outputPath {
+			errs = append(errs, pkgErr)
+			continue
+		}
+
+		location := fmt.Sprintf("%d:%d", line, col)
+		switch funcName, caseDescr := spec.testCaseAt(lines, line); {
+		case caseDescr != "":
+			location = fmt.Sprintf("%s: %q: %s", funcName, caseDescr, location)
+		case funcName != "":
+			location = fmt.Sprintf("%s: %s", funcName, location)
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", location, pkgErr.Msg))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("generated tests do not compile: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// testCaseAt returns the function and test case description of the spec generating a line of the rendered tests
+func (spec GenerationSpec) testCaseAt(lines []string, line int) (string, string) {
+	funcName, caseName := enclosingTest(lines, line)
+	for _, funcSpec := range spec.FunctionSpecs {
+		if testName(funcSpec.Func) != funcName {
+			continue
+		}
+		for _, testCase := range funcSpec.TestCases {
+			if caseName != "" && sanitizeName(testCase.CaseDescr) == caseName {
+				return funcSpec.Func, testCase.CaseDescr
+			}
+		}
+		return funcSpec.Func, ""
+	}
+	return "", ""
+}
+
+// errorPosPattern matches the position of a package error, formatted as file:line:col or file:line
+var errorPosPattern = regexp.MustCompile(`^(.*?):(\d+)(?::(\d+))?$`)
+
+// splitErrorPos splits the position of a package error into its file, line and column
+func splitErrorPos(pos string) (string, int, int) {
+	match := errorPosPattern.FindStringSubmatch(pos)
+	if match == nil {
+		return pos, 0, 0
+	}
+
+	line, _ := strconv.Atoi(match[2])
+	col, _ := strconv.Atoi(match[3])
+	return match[1], line, col
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyTests(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/verify\n\ngo 1.24\n",
+		"handler.go": `package handler
+
+import "net/http"
+
+func PingHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	newSpec := func(funcName string) GenerationSpec {
+		return GenerationSpec{
+			PackageName: "handler",
+			FunctionSpecs: []FunctionTestSpec{{
+				Func: funcName,
+				TestCases: []EnhancedTestCase{{TestCase: TestCase{
+					CaseDescr: "it should pong",
+					Response:  Response{StatusCode: 200},
+				}}},
+			}},
+		}
+	}
+
+	verify := func(t *testing.T, spec GenerationSpec) error {
+		t.Helper()
+
+		src, err := renderTests(spec)
+		if err != nil {
+			t.Fatalf("could not render tests: %v", err)
+		}
+		return verifyTests(spec, filepath.Join(dir, "handler_test.go"), src)
+	}
+
+	t.Run("it should accept tests that compile", func(t *testing.T) {
+		if err := verify(t, newSpec("PingHandler")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("it should report errors with the function and case generating them", func(t *testing.T) {
+		err := verify(t, newSpec("PongHandler"))
+		if err == nil {
+			t.Fatal("expected an error, got none")
+		}
+
+		if msg := err.Error(); !strings.Contains(msg, `PongHandler: "it should pong": `) || !strings.Contains(msg, "undefined: PongHandler") {
+			t.Fatalf("expected the error to point to the test case, got: %s", msg)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "handler_test.go")); !os.IsNotExist(err) {
+			t.Fatalf("expected the tests not to be written, got: %v", err)
+		}
+	})
+}
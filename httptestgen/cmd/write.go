@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// generatedHeader is the first line of the files written by httptestgen, matching the header of test.tpl
+const generatedHeader = "// Code generated by httptestgen. DO NOT EDIT."
+
+// errNotGenerated is returned when the output file exists and was not generated by httptestgen
+var errNotGenerated = errors.New("output file was not generated by httptestgen")
+
+// writeTests replaces the output file with the generated tests. Existing files are replaced only if they were
+// generated by httptestgen, unless force is set.
+func writeTests(outputFile string, src []byte, force bool) error {
+	_, err := os.Stat(outputFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("could not stat %s: %w", outputFile, err)
+	case !force:
+		generated, err := isGenerated(outputFile)
+		if err != nil {
+			return err
+		}
+		if !generated {
+			return fmt.Errorf("%w: %s does not start with %q, pass -force to overwrite it", errNotGenerated, outputFile, generatedHeader)
+		}
+	}
+
+	return replaceFile(outputFile, src)
+}
+
+// replaceFile writes data to a temporary file renamed over file, so that file is never left partially written.
+// Existing files keep their permissions.
+func replaceFile(file string, data []byte) error {
+	mode := fs.FileMode(0o644)
+
+	stat, err := os.Stat(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("could not stat %s: %w", file, err)
+	default:
+		mode = stat.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not set permissions of temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("could not replace %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// isGenerated reports whether a file starts with the header of the files generated by httptestgen
+func isGenerated(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, fmt.Errorf("could not open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("could not read %s: %w", file, err)
+	}
+
+	return bytes.Equal(bytes.TrimRight(line, "\r\n"), []byte(generatedHeader)), nil
+}
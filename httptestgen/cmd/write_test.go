@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTests(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		generated = []byte(generatedHeader + "\n\npackage handler\n")
+	)
+
+	readFile := func(t *testing.T, name string) string {
+		t.Helper()
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", name, err)
+		}
+		return string(content)
+	}
+
+	t.Run("it should render tests starting with the generated header", func(t *testing.T) {
+		src, err := renderTests(GenerationSpec{PackageName: "handler"})
+		if err != nil {
+			t.Fatalf("could not render tests: %v", err)
+		}
+		if !strings.HasPrefix(string(src), generatedHeader+"\n") {
+			t.Fatalf("expected the generated header, got:\n%s", src)
+		}
+	})
+
+	t.Run("it should create missing output files", func(t *testing.T) {
+		outputFile := filepath.Join(dir, "new_test.go")
+		if err := writeTests(outputFile, generated, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := readFile(t, outputFile); got != string(generated) {
+			t.Fatalf("unexpected content:\n%s", got)
+		}
+	})
+
+	t.Run("it should replace generated files", func(t *testing.T) {
+		outputFile := filepath.Join(dir, "generated_test.go")
+		if err := os.WriteFile(outputFile, []byte(generatedHeader+"\n\npackage old\n"), 0o600); err != nil {
+			t.Fatalf("could not write output: %v", err)
+		}
+
+		if err := writeTests(outputFile, generated, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := readFile(t, outputFile); got != string(generated) {
+			t.Fatalf("unexpected content:\n%s", got)
+		}
+
+		stat, err := os.Stat(outputFile)
+		if err != nil {
+			t.Fatalf("could not stat output: %v", err)
+		}
+		if mode := stat.Mode().Perm(); mode != 0o600 {
+			t.Fatalf("expected the permissions to be kept, got %v", mode)
+		}
+	})
+
+	t.Run("it should refuse to overwrite hand written files unless forced", func(t *testing.T) {
+		outputFile := filepath.Join(dir, "handwritten_test.go")
+		handwritten := "package handler\n\n// Code generated by httptestgen. DO NOT EDIT.\n"
+		if err := os.WriteFile(outputFile, []byte(handwritten), 0o644); err != nil {
+			t.Fatalf("could not write output: %v", err)
+		}
+
+		if err := writeTests(outputFile, generated, false); !errors.Is(err, errNotGenerated) {
+			t.Fatalf("expected the file not to be overwritten, got: %v", err)
+		}
+		if got := readFile(t, outputFile); got != handwritten {
+			t.Fatalf("unexpected content:\n%s", got)
+		}
+
+		if err := writeTests(outputFile, generated, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := readFile(t, outputFile); got != string(generated) {
+			t.Fatalf("unexpected content:\n%s", got)
+		}
+	})
+
+	t.Run("it should not leave temporary files behind", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("could not read dir: %v", err)
+		}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".tmp") {
+				t.Fatalf("unexpected temporary file %s", entry.Name())
+			}
+		}
+	})
+}
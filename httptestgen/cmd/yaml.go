@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// yamlError is a YAML value that cannot be converted to JSON
+type yamlError struct {
+	Pos position
+	Msg string
+}
+
+func (e *yamlError) Error() string {
+	return e.Msg
+}
+
+// isYAML reports whether a spec is written in YAML, by the extension of its file
+func isYAML(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// indexSpec indexes a JSON or YAML spec, chosen by the extension of its file
+func indexSpec(file string, data []byte) (*specIndex, error) {
+	if isYAML(file) {
+		return indexYAML(file, data)
+	}
+	return indexJSON(file, data)
+}
+
+// yamlConverter converts YAML documents to JSON, indexing the values of the JSON document at their YAML position
+type yamlConverter struct {
+	idx        *specIndex
+	lineStarts []int
+	out        bytes.Buffer
+}
+
+// indexYAML converts a YAML document to JSON, resolving anchors and merge keys,
+// and records the position of every value and mapping key in the YAML document
+func indexYAML(file string, data []byte) (*specIndex, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	c := &yamlConverter{
+		idx: &specIndex{
+			file:   file,
+			data:   data,
+			values: make(map[string]int),
+			keys:   make(map[string]int),
+		},
+		lineStarts: []int{0},
+	}
+	for i, b := range data {
+		if b == '\n' {
+			c.lineStarts = append(c.lineStarts, i+1)
+		}
+	}
+
+	if len(doc.Content) == 0 {
+		c.out.WriteString("null")
+	} else if err := c.convert(doc.Content[0], nil); err != nil {
+		return nil, err
+	}
+
+	c.idx.json = c.out.Bytes()
+	return c.idx, nil
+}
+
+// offset returns the byte offset of a node, whose column counts characters
+func (c *yamlConverter) offset(node *yaml.Node) int {
+	if node.Line < 1 || node.Line > len(c.lineStarts) {
+		return 0
+	}
+
+	offset := c.lineStarts[node.Line-1]
+	for range node.Column - 1 {
+		_, size := utf8.DecodeRune(c.idx.data[offset:])
+		if size == 0 {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+func (c *yamlConverter) errorf(node *yaml.Node, format string, args ...any) error {
+	return &yamlError{Pos: c.idx.offsetPos(c.offset(node)), Msg: fmt.Sprintf(format, args...)}
+}
+
+// convert writes a node as JSON, recording its position at path
+func (c *yamlConverter) convert(node *yaml.Node, path []string) error {
+	c.idx.values[pathKey(path)] = c.offset(node)
+
+	// Aliases are positioned where they are used, the values they refer to where they are anchored
+	node = resolveAlias(node)
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		pairs, err := c.mappingPairs(node)
+		if err != nil {
+			return err
+		}
+
+		c.out.WriteByte('{')
+		for i, pair := range pairs {
+			if i > 0 {
+				c.out.WriteByte(',')
+			}
+
+			keyPath := append(path[:len(path):len(path)], pair.key.Value)
+			c.idx.keys[pathKey(keyPath)] = c.offset(pair.key)
+			c.writeString(pair.key.Value)
+			c.out.WriteByte(':')
+			if err := c.convert(pair.value, keyPath); err != nil {
+				return err
+			}
+		}
+		c.out.WriteByte('}')
+	case yaml.SequenceNode:
+		c.out.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				c.out.WriteByte(',')
+			}
+			if err := c.convert(item, append(path[:len(path):len(path)], strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		c.out.WriteByte(']')
+	case yaml.ScalarNode:
+		return c.convertScalar(node)
+	default:
+		return c.errorf(node, "unsupported YAML node")
+	}
+
+	return nil
+}
+
+// convertScalar writes a scalar as JSON. Strings, timestamps and binary data are written as strings
+// and numbers keep the literal they are written with whenever it is valid in JSON.
+func (c *yamlConverter) convertScalar(node *yaml.Node) error {
+	switch node.ShortTag() {
+	case "!!null":
+		c.out.WriteString("null")
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return c.errorf(node, "invalid boolean %q", node.Value)
+		}
+		c.out.WriteString(strconv.FormatBool(b))
+	case "!!int", "!!float":
+		number, err := yamlNumber(node)
+		if err != nil {
+			return c.errorf(node, "%s", err)
+		}
+		c.out.WriteString(number)
+	default:
+		c.writeString(node.Value)
+	}
+	return nil
+}
+
+// yamlNumber returns the JSON literal of a YAML number, such as 31 for 0x1F
+func yamlNumber(node *yaml.Node) (string, error) {
+	if _, err := strconv.ParseFloat(node.Value, 64); err == nil && json.Valid([]byte(node.Value)) {
+		return node.Value, nil
+	}
+
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return "", fmt.Errorf("invalid number %q", node.Value)
+	}
+
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return "", fmt.Errorf("%s cannot be represented in JSON", node.Value)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("invalid number %q", node.Value)
+}
+
+// writeString writes a JSON string, leaving <, > and & as they are written in the YAML document
+func (c *yamlConverter) writeString(s string) {
+	encoder := json.NewEncoder(&c.out)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	// Encode terminates the value with a newline
+	c.out.Truncate(c.out.Len() - 1)
+}
+
+// yamlPair is a key value pair of a YAML mapping
+type yamlPair struct {
+	key, value *yaml.Node
+}
+
+// mappingPairs returns the pairs of a mapping with merge keys (<<) resolved: merged pairs take the place
+// of the merge key, keys declared in the mapping override merged ones and earlier merged mappings override later ones
+func (c *yamlConverter) mappingPairs(node *yaml.Node) ([]yamlPair, error) {
+	declared := make(map[string]bool)
+	for i := 0; i < len(node.Content); i += 2 {
+		if key := resolveAlias(node.Content[i]); !isMergeKey(key) {
+			declared[key.Value] = true
+		}
+	}
+
+	var (
+		pairs []yamlPair
+		seen  = make(map[string]bool)
+	)
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := resolveAlias(node.Content[i]), node.Content[i+1]
+
+		if !isMergeKey(key) {
+			if key.Kind != yaml.ScalarNode {
+				return nil, c.errorf(key, "mapping keys must be strings")
+			}
+			seen[key.Value] = true
+			pairs = append(pairs, yamlPair{key: key, value: value})
+			continue
+		}
+
+		merged := []*yaml.Node{resolveAlias(value)}
+		if merged[0].Kind == yaml.SequenceNode {
+			merged = merged[0].Content
+		}
+		for _, m := range merged {
+			if m = resolveAlias(m); m.Kind != yaml.MappingNode {
+				return nil, c.errorf(m, "merge keys must refer to a mapping or a list of mappings")
+			}
+
+			mergedPairs, err := c.mappingPairs(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, pair := range mergedPairs {
+				if !declared[pair.key.Value] && !seen[pair.key.Value] {
+					seen[pair.key.Value] = true
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// isMergeKey reports whether a mapping key merges other mappings into its mapping
+func isMergeKey(key *yaml.Node) bool {
+	return key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge"
+}
+
+// resolveAlias returns the node an alias refers to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// jsonToYAML converts a JSON document to YAML, keeping the order of object keys, the literals of numbers
+// and writing multi-line strings as block scalars
+func jsonToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	node, err := yamlNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// yamlNode decodes the next JSON value as a YAML node
+func yamlNode(decoder *json.Decoder) (*yaml.Node, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if tok == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+
+			value, err := yamlNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+
+		// Closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tok}
+		if strings.Contains(tok, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		return node, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(tok), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(tok)}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(tok)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+
+	return nil, errors.New("unexpected JSON token")
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIndexYAML(t *testing.T) {
+	const spec = `# Shared parts of the requests
+- func: CreateUserHandler
+  test-cases:
+    - case_descr: it should create users
+      request: &create
+        method: POST
+        path: /users
+        body:
+          id: 9007199254740993
+          hex: 0x1F
+          ratio: 1.50
+          created: 2024-01-01T00:00:00Z
+          bio: |
+            Gopher
+            since 2009
+      response:
+        status_code: "201"
+    - case_descr: it should reuse requests
+      request:
+        <<: *create
+        path: /v2/users
+      response:
+        status_code: "201"
+`
+
+	idx, err := indexYAML("spec.yaml", []byte(spec))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var specs []struct {
+		Cases []struct {
+			Request struct {
+				Method string         `json:"method"`
+				Path   string         `json:"path"`
+				Body   map[string]any `json:"body"`
+			} `json:"request"`
+		} `json:"test-cases"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(idx.json))
+	decoder.UseNumber()
+	if err := decoder.Decode(&specs); err != nil {
+		t.Fatalf("could not decode %s: %v", idx.json, err)
+	}
+
+	t.Run("it should keep numbers as written when they are valid JSON", func(t *testing.T) {
+		body := specs[0].Cases[0].Request.Body
+		for key, expected := range map[string]string{"id": "9007199254740993", "hex": "31", "ratio": "1.50"} {
+			if got := body[key]; got != json.Number(expected) {
+				t.Errorf("unexpected %s: got %v want %s", key, got, expected)
+			}
+		}
+	})
+
+	t.Run("it should convert timestamps and block scalars to strings", func(t *testing.T) {
+		body := specs[0].Cases[0].Request.Body
+		if got := body["created"]; got != "2024-01-01T00:00:00Z" {
+			t.Errorf("unexpected created: %v", got)
+		}
+		if got := body["bio"]; got != "Gopher\nsince 2009\n" {
+			t.Errorf("unexpected bio: %q", got)
+		}
+	})
+
+	t.Run("it should resolve merge keys, keeping the keys of the mapping", func(t *testing.T) {
+		request := specs[0].Cases[1].Request
+		if request.Method != "POST" || request.Path != "/v2/users" || request.Body["id"] != json.Number("9007199254740993") {
+			t.Fatalf("unexpected request: %+v", request)
+		}
+	})
+
+	t.Run("it should position values and keys in the YAML document", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"0/test-cases/0/request/body/hex": "spec.yaml:10:16",
+			"0/test-cases/1/request/path":     "spec.yaml:21:15",
+			"0/test-cases/1/request":          "spec.yaml:20:9",
+		} {
+			if got := idx.valuePos(strings.Split(path, "/")).String(); got != expected {
+				t.Errorf("unexpected position of %s: got %s want %s", path, got, expected)
+			}
+		}
+		if got := idx.keyPos([]string{"0", "test-cases", "1", "request", "path"}).String(); got != "spec.yaml:21:9" {
+			t.Errorf("unexpected key position: %s", got)
+		}
+	})
+}
+
+func TestValidateYAMLSpec(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		spec     string
+		expected []string
+	}{
+		{
+			name: "it should report problems at their position in the YAML document",
+			spec: `- func: CreateUserHandler
+  test_cases: []
+- func: HealthCheckHandler
+  test-cases:
+    - case_descr: it should be healthy
+      response:
+        status_code: [200]
+        headers:
+          Content Type: application/json
+`,
+			expected: []string{
+				`spec.yml:1:3: missing property 'test-cases'`,
+				`spec.yml:2:3: unknown property "test_cases"`,
+				`spec.yml:7:22: got array, want integer or string`,
+				`spec.yml:9:11: invalid name "Content Type"`,
+			},
+		},
+		{
+			name: "it should report values that cannot be converted to JSON",
+			spec: `- func: HealthCheckHandler
+  test-cases:
+    - case_descr: it should not be infinite
+      response:
+        status_code: "200"
+        body: {value: .inf}
+`,
+			expected: []string{`spec.yml:6:23: .inf cannot be represented in JSON`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			diags, _ := validateSpec("spec.yml", []byte(tt.spec))
+
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.String())
+			}
+			if !slices.Equal(tt.expected, got) {
+				t.Fatalf("unexpected diagnostics:\ngot:  %q\nwant: %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertSpec(t *testing.T) {
+	t.Run("it should convert JSON specs to YAML and back without changes", func(t *testing.T) {
+		original, err := os.ReadFile("../examples/handler/testdata/testcases.json")
+		if err != nil {
+			t.Fatalf("could not read spec: %v", err)
+		}
+
+		yamlSpec, err := convertSpec(original, true)
+		if err != nil {
+			t.Fatalf("could not convert to YAML: %v", err)
+		}
+
+		idx, err := indexYAML("testcases.yaml", yamlSpec)
+		if err != nil {
+			t.Fatalf("could not parse converted YAML: %v\n%s", err, yamlSpec)
+		}
+
+		jsonSpec, err := convertSpec(idx.json, false)
+		if err != nil {
+			t.Fatalf("could not convert to JSON: %v", err)
+		}
+
+		if got, want := strings.TrimSpace(string(jsonSpec)), strings.TrimSpace(string(original)); got != want {
+			t.Fatalf("spec changed in the round trip:\n%s", unifiedDiff("original", "converted", []byte(want), []byte(got)))
+		}
+	})
+
+	t.Run("it should write multi-line strings as block scalars and quote strings looking like other values", func(t *testing.T) {
+		got, err := convertSpec([]byte(`{"bio": "Gopher\nsince 2009\n", "status_code": "201", "ok": "true", "id": 1, "ratio": 1.50}`), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := `bio: |
+  Gopher
+  since 2009
+status_code: "201"
+ok: "true"
+id: 1
+ratio: 1.50
+`
+		if string(got) != expected {
+			t.Fatalf("unexpected YAML:\n%s", got)
+		}
+	})
+}
+
+func TestRunConvert(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "testcases.json")
+	if err := os.WriteFile(input, []byte(`[{"func": "PingHandler", "test-cases": []}]`), 0o644); err != nil {
+		t.Fatalf("could not write spec: %v", err)
+	}
+
+	t.Run("it should refuse to overwrite existing files unless forced", func(t *testing.T) {
+		output := filepath.Join(dir, "testcases.yaml")
+		if err := os.WriteFile(output, []byte("# hand written\n"), 0o600); err != nil {
+			t.Fatalf("could not write output: %v", err)
+		}
+
+		err := runConvert([]string{"-testcases", input, "-output", output}, io.Discard)
+		if !errors.Is(err, fs.ErrExist) {
+			t.Fatalf("expected the output to exist, got: %v", err)
+		}
+		if content, _ := os.ReadFile(output); string(content) != "# hand written\n" {
+			t.Fatalf("expected the output to be left as it was, got:\n%s", content)
+		}
+
+		if err := runConvert([]string{"-testcases", input, "-output", output, "-force"}, io.Discard); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if content, _ := os.ReadFile(output); string(content) != "- func: PingHandler\n  test-cases: []\n" {
+			t.Fatalf("unexpected output:\n%s", content)
+		}
+		if stat, err := os.Stat(output); err != nil || stat.Mode().Perm() != 0o600 {
+			t.Fatalf("expected the output to keep its permissions, got: %v, %v", stat.Mode(), err)
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 2 {
+			t.Fatalf("expected no temporary file to be left, got: %v", entries)
+		}
+	})
+}
@@ -1,36 +1,15 @@
-//go:generate cmd -input=handler.go -output=handler_test.go -testcases=testdata/testcases.json -request-type=CreateUserRequest
+//go:generate go tool mockgen -package handler -source handler.go -destination handler_mock_test.go
+//go:generate cmd -input=handler.go -output=handler_test.go -testcases=testdata/testcases.json
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-)
-
-type (
-	// User represents a user in our system
-	User struct {
-		ID    int    `json:"id"`
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}
-	// CreateUserRequest represents the request payload for creating a user
-	CreateUserRequest struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
-	}
-
-	// CreateUserResponse represents the response when creating a user
-	CreateUserResponse struct {
-		User    User   `json:"user"`
-		Message string `json:"message"`
-	}
-
-	// ErrorResponse represents an error response
-	ErrorResponse struct {
-		Error   string `json:"error"`
-		Code    string `json:"code"`
-		Details string `json:"details,omitempty"`
-	}
+	"strconv"
+	"time"
 )
 
 // CreateUserHandler handles user creation requests
@@ -87,5 +66,96 @@ func GetUserHandler(w http.ResponseWriter, r *http.Request) {
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok","timestamp":"2024-01-01T00:00:00Z"}`))
+	fmt.Fprintf(w, `{"status":"ok","timestamp":%q}`, time.Now().UTC().Format(time.RFC3339))
+}
+
+// NewRouter routes requests to the handlers by method and path
+func NewRouter() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users", CreateUserHandler)
+	mux.HandleFunc("GET /users/{id}", GetUserHandler)
+	mux.HandleFunc("GET /health", HealthCheckHandler)
+	return mux
+}
+
+// UserServer serves the users it stores
+type UserServer struct {
+	users map[int]User
+}
+
+// NewUserServer returns a server storing the given users
+func NewUserServer(users ...User) *UserServer {
+	s := &UserServer{users: make(map[int]User, len(users))}
+	for _, user := range users {
+		s.users[user.ID] = user
+	}
+	return s
+}
+
+// GetUser handles the retrieval of the user whose ID is in the path
+func (s *UserServer) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	user, ok := s.users[id]
+	if err != nil || !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error: "User not found",
+			Code:  "NOT_FOUND",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// ErrUserNotFound is returned by stores not storing the requested user
+var ErrUserNotFound = errors.New("user not found")
+
+// UserStore retrieves users
+type UserStore interface {
+	GetUser(ctx context.Context, id int) (User, error)
+}
+
+// StoreServer serves the users of a UserStore
+type StoreServer struct {
+	store UserStore
+}
+
+// NewStoreServer returns a server serving the users of store
+func NewStoreServer(store UserStore) *StoreServer {
+	return &StoreServer{store: store}
+}
+
+// GetUser handles the retrieval of the user whose ID is in the path from the store
+func (s *StoreServer) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUser(r.Context(), id)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error: "User not found",
+			Code:  "NOT_FOUND",
+		})
+		return
+	case err != nil:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error: "Could not get user",
+			Code:  "INTERNAL",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
 }
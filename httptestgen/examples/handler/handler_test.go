@@ -6,10 +6,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
+	"maps"
+	"math/big"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"go.uber.org/mock/gomock"
 )
 
 func TestCreateUserHandler(t *testing.T) {
@@ -29,82 +43,122 @@ func TestCreateUserHandler(t *testing.T) {
 		}
 		reqReader = bytes.NewReader(requestBody)
 		req := httptest.NewRequestWithContext(ctx, "POST", "/users", reqReader)
+		req.Header.Add("Authorization", "Bearer token123")
+		req.Header.Add("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		CreateUserHandler(rr, req)
 
-		if status := rr.Code; status != 201 {
-			t.Errorf("CreateUserHandler returned wrong status code: got %v want 201", status)
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("CreateUserHandler returned wrong status code: got %v want %v", status, http.StatusCreated)
 		}
 
-		expectedBody := `{"message":"User created successfully","user":{"email":"andrea@gitpod.io","id":1,"name":"Andrea"}}`
+		// Result returns the headers as they were when the status code was written.
+		headers := rr.Result().Header
+		if got, want := headers.Values("Content-Type"), []string{"application/json"}; !slices.Equal(got, want) {
+			t.Errorf("CreateUserHandler returned wrong %s header: got %q want %q", "Content-Type", got, want)
+		}
 
-		var expected, actual map[string]any
-		if err := json.Unmarshal([]byte(expectedBody), &expected); err != nil {
-			t.Fatalf("Failed to unmarshal expected response: %v", err)
+		expectedResponse := CreateUserResponse{
+			User:    User{ID: 1, Name: "Andrea", Email: "andrea@gitpod.io"},
+			Message: "User created successfully",
 		}
 
-		if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+		var actualResponse CreateUserResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
 			t.Fatalf("Failed to unmarshal actual response: %v", err)
 		}
 
-		expectedJSON, err := json.Marshal(expected)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling expected json: %v\n", err)
+		expectedUserJSON, _ := json.Marshal(expectedResponse.User)
+		actualUserJSON, _ := json.Marshal(actualResponse.User)
+		if string(expectedUserJSON) != string(actualUserJSON) {
+			t.Errorf("CreateUserHandler field User mismatch:\ngot:  %s\nwant: %s", string(actualUserJSON), string(expectedUserJSON))
 		}
-		actualJSON, err := json.Marshal(actual)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling actual json: %v\n", err)
+		if expectedResponse.Message != actualResponse.Message {
+			t.Errorf("CreateUserHandler field Message mismatch:\ngot:  %q\nwant: %q", actualResponse.Message, expectedResponse.Message)
 		}
 
-		if string(expectedJSON) != string(actualJSON) {
-			t.Errorf("CreateUserHandler returned unexpected body:\ngot:  %s\nwant: %s", string(actualJSON), string(expectedJSON))
+		for _, problem := range assertJSON(rr.Body.Bytes(), []jsonAssertion{
+			{Path: "$.user.id", Op: ">", Value: `0`},
+			{Path: "$.message", Op: "contains", Value: `"created"`},
+		}) {
+			t.Errorf("CreateUserHandler returned unexpected body: %s", problem)
 		}
 	})
 
 	t.Run("it_should_return_a_bad_request_when_the_request_is_invalid", func(t *testing.T) {
 		var reqReader io.Reader = nil
-		requestData := CreateUserRequest{
-			Name:  "Andrea",
-			Email: "",
+		// The request body does not fit CreateUserRequest and is sent as written in the spec.
+		reqReader = bytes.NewReader([]byte(`{"name":"Andrea","email":123}`))
+		req := httptest.NewRequestWithContext(ctx, "POST", "/users", reqReader)
+		req.Header.Add("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		CreateUserHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("CreateUserHandler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 		}
 
-		requestBody, err := json.Marshal(requestData)
-		if err != nil {
-			t.Fatalf("Failed to marshal request: %v", err)
+		// Result returns the headers as they were when the status code was written.
+		headers := rr.Result().Header
+		if got, want := headers.Values("Content-Type"), []string{"application/json"}; !slices.Equal(got, want) {
+			t.Errorf("CreateUserHandler returned wrong %s header: got %q want %q", "Content-Type", got, want)
 		}
-		reqReader = bytes.NewReader(requestBody)
+
+		expectedResponse := ErrorResponse{
+			Error: "Invalid request",
+			Code:  "INVALID_INPUT",
+		}
+
+		var actualResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
+			t.Fatalf("Failed to unmarshal actual response: %v", err)
+		}
+
+		if expectedResponse.Error != actualResponse.Error {
+			t.Errorf("CreateUserHandler field Error mismatch:\ngot:  %q\nwant: %q", actualResponse.Error, expectedResponse.Error)
+		}
+		if expectedResponse.Code != actualResponse.Code {
+			t.Errorf("CreateUserHandler field Code mismatch:\ngot:  %q\nwant: %q", actualResponse.Code, expectedResponse.Code)
+		}
+	})
+
+	t.Run("it_should_return_a_bad_request_when_the_email_is_missing", func(t *testing.T) {
+		var reqReader io.Reader = nil
+		// The request body is declared raw and is sent as written in the spec.
+		reqReader = bytes.NewReader([]byte(`{"name":"Andrea"}`))
 		req := httptest.NewRequestWithContext(ctx, "POST", "/users", reqReader)
+		req.Header.Add("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		CreateUserHandler(rr, req)
 
-		if status := rr.Code; status != 400 {
-			t.Errorf("CreateUserHandler returned wrong status code: got %v want 400", status)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("CreateUserHandler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 		}
 
-		expectedBody := `{"code":"INVALID_INPUT","error":"Invalid request"}`
+		// Result returns the headers as they were when the status code was written.
+		headers := rr.Result().Header
+		if got, want := headers.Values("Content-Type"), []string{"application/json"}; !slices.Equal(got, want) {
+			t.Errorf("CreateUserHandler returned wrong %s header: got %q want %q", "Content-Type", got, want)
+		}
 
-		var expected, actual map[string]any
-		if err := json.Unmarshal([]byte(expectedBody), &expected); err != nil {
-			t.Fatalf("Failed to unmarshal expected response: %v", err)
+		expectedResponse := ErrorResponse{
+			Error: "Invalid request",
+			Code:  "INVALID_INPUT",
 		}
 
-		if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+		var actualResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
 			t.Fatalf("Failed to unmarshal actual response: %v", err)
 		}
 
-		expectedJSON, err := json.Marshal(expected)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling expected json: %v\n", err)
-		}
-		actualJSON, err := json.Marshal(actual)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling actual json: %v\n", err)
+		if expectedResponse.Error != actualResponse.Error {
+			t.Errorf("CreateUserHandler field Error mismatch:\ngot:  %q\nwant: %q", actualResponse.Error, expectedResponse.Error)
 		}
-
-		if string(expectedJSON) != string(actualJSON) {
-			t.Errorf("CreateUserHandler returned unexpected body:\ngot:  %s\nwant: %s", string(actualJSON), string(expectedJSON))
+		if expectedResponse.Code != actualResponse.Code {
+			t.Errorf("CreateUserHandler field Code mismatch:\ngot:  %q\nwant: %q", actualResponse.Code, expectedResponse.Code)
 		}
 	})
 }
@@ -115,36 +169,41 @@ func TestGetUserHandler(t *testing.T) {
 	t.Run("it_should_return_user_when_valid_ID_is_provided", func(t *testing.T) {
 		var reqReader io.Reader = nil
 		req := httptest.NewRequestWithContext(ctx, "GET", "/users/123", reqReader)
+		req.SetPathValue("id", "123")
+		req.Header.Add("Authorization", "Bearer token123")
 
 		rr := httptest.NewRecorder()
 		GetUserHandler(rr, req)
 
-		if status := rr.Code; status != 200 {
-			t.Errorf("GetUserHandler returned wrong status code: got %v want 200", status)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("GetUserHandler returned wrong status code: got %v want %v", status, http.StatusOK)
 		}
 
-		expectedBody := `{"email":"jane@example.com","id":123,"name":"Jane Smith"}`
+		// Result returns the headers as they were when the status code was written.
+		headers := rr.Result().Header
+		if got, want := headers.Values("Content-Type"), []string{"application/json"}; !slices.Equal(got, want) {
+			t.Errorf("GetUserHandler returned wrong %s header: got %q want %q", "Content-Type", got, want)
+		}
 
-		var expected, actual map[string]any
-		if err := json.Unmarshal([]byte(expectedBody), &expected); err != nil {
-			t.Fatalf("Failed to unmarshal expected response: %v", err)
+		expectedResponse := User{
+			ID:    123,
+			Name:  "Jane Smith",
+			Email: "jane@example.com",
 		}
 
-		if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+		var actualResponse User
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
 			t.Fatalf("Failed to unmarshal actual response: %v", err)
 		}
 
-		expectedJSON, err := json.Marshal(expected)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling expected json: %v\n", err)
+		if expectedResponse.ID != actualResponse.ID {
+			t.Errorf("GetUserHandler field ID mismatch:\ngot:  %d\nwant: %d", actualResponse.ID, expectedResponse.ID)
 		}
-		actualJSON, err := json.Marshal(actual)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling actual json: %v\n", err)
+		if expectedResponse.Name != actualResponse.Name {
+			t.Errorf("GetUserHandler field Name mismatch:\ngot:  %q\nwant: %q", actualResponse.Name, expectedResponse.Name)
 		}
-
-		if string(expectedJSON) != string(actualJSON) {
-			t.Errorf("GetUserHandler returned unexpected body:\ngot:  %s\nwant: %s", string(actualJSON), string(expectedJSON))
+		if expectedResponse.Email != actualResponse.Email {
+			t.Errorf("GetUserHandler field Email mismatch:\ngot:  %q\nwant: %q", actualResponse.Email, expectedResponse.Email)
 		}
 	})
 }
@@ -159,32 +218,670 @@ func TestHealthCheckHandler(t *testing.T) {
 		rr := httptest.NewRecorder()
 		HealthCheckHandler(rr, req)
 
-		if status := rr.Code; status != 200 {
-			t.Errorf("HealthCheckHandler returned wrong status code: got %v want 200", status)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("HealthCheckHandler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		// Result returns the headers as they were when the status code was written.
+		headers := rr.Result().Header
+		if got, want := headers.Values("Content-Type"), []string{"application/json"}; !slices.Equal(got, want) {
+			t.Errorf("HealthCheckHandler returned wrong %s header: got %q want %q", "Content-Type", got, want)
+		}
+
+		problems, err := matchJSON(rr.Body.Bytes(), `{"status":"ok","timestamp":{"$rfc3339":true}}`, jsonMatch{})
+		if err != nil {
+			t.Fatalf("Failed to unmarshal actual response: %v", err)
+		}
+		if len(problems) > 0 {
+			t.Errorf("HealthCheckHandler returned unexpected body:\n%s", strings.Join(problems, "\n"))
+		}
+	})
+}
+func TestUserServer_GetUser(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("it_should_return_stored_users", func(t *testing.T) {
+		receiver := NewUserServer(User{ID: 123, Name: "Jane Smith", Email: "jane@example.com"})
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/123", reqReader)
+		req.SetPathValue("id", "123")
+
+		rr := httptest.NewRecorder()
+		receiver.GetUser(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("UserServer.GetUser returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		assertGolden(t, "testdata/user_server_get_user.golden", rr.Body.Bytes())
+	})
+
+	t.Run("it_should_return_not_found_for_unknown_users", func(t *testing.T) {
+		receiver := NewUserServer(User{ID: 123, Name: "Jane Smith", Email: "jane@example.com"})
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/1", reqReader)
+		req.SetPathValue("id", "1")
+
+		rr := httptest.NewRecorder()
+		receiver.GetUser(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("UserServer.GetUser returned wrong status code: got %v want %v", status, http.StatusNotFound)
 		}
 
-		expectedBody := `{"status":"ok","timestamp":"2024-01-01T00:00:00Z"}`
+		expectedResponse := ErrorResponse{
+			Error: "User not found",
+			Code:  "NOT_FOUND",
+		}
 
-		var expected, actual map[string]any
-		if err := json.Unmarshal([]byte(expectedBody), &expected); err != nil {
-			t.Fatalf("Failed to unmarshal expected response: %v", err)
+		var actualResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
+			t.Fatalf("Failed to unmarshal actual response: %v", err)
 		}
 
-		if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+		if expectedResponse.Error != actualResponse.Error {
+			t.Errorf("UserServer.GetUser field Error mismatch:\ngot:  %q\nwant: %q", actualResponse.Error, expectedResponse.Error)
+		}
+		if expectedResponse.Code != actualResponse.Code {
+			t.Errorf("UserServer.GetUser field Code mismatch:\ngot:  %q\nwant: %q", actualResponse.Code, expectedResponse.Code)
+		}
+
+		for _, problem := range assertJSON(rr.Body.Bytes(), []jsonAssertion{
+			{Path: "$.code", Op: "==", Value: `"NOT_FOUND"`},
+			{Path: "$.details", Op: "exists", Value: `false`},
+		}) {
+			t.Errorf("UserServer.GetUser returned unexpected body: %s", problem)
+		}
+	})
+}
+func TestStoreServer_GetUser(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("it_should_return_users_found_in_the_store", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := NewMockUserStore(ctrl)
+		store.EXPECT().GetUser(gomock.Any(), 123).Return(User{ID: 123, Name: "Jane Smith", Email: "jane@example.com"}, nil)
+
+		receiver := NewStoreServer(store)
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/123", reqReader)
+		req.SetPathValue("id", "123")
+
+		rr := httptest.NewRecorder()
+		receiver.GetUser(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("StoreServer.GetUser returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		expectedResponse := User{
+			ID:    123,
+			Name:  "Jane Smith",
+			Email: "jane@example.com",
+		}
+
+		var actualResponse User
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
 			t.Fatalf("Failed to unmarshal actual response: %v", err)
 		}
 
-		expectedJSON, err := json.Marshal(expected)
-		if err != nil {
-			t.Fatalf("unexpected error while json marshalling expected json: %v\n", err)
+		if expectedResponse.ID != actualResponse.ID {
+			t.Errorf("StoreServer.GetUser field ID mismatch:\ngot:  %d\nwant: %d", actualResponse.ID, expectedResponse.ID)
+		}
+		if expectedResponse.Name != actualResponse.Name {
+			t.Errorf("StoreServer.GetUser field Name mismatch:\ngot:  %q\nwant: %q", actualResponse.Name, expectedResponse.Name)
+		}
+		if expectedResponse.Email != actualResponse.Email {
+			t.Errorf("StoreServer.GetUser field Email mismatch:\ngot:  %q\nwant: %q", actualResponse.Email, expectedResponse.Email)
+		}
+	})
+
+	t.Run("it_should_return_not_found_for_users_missing_from_the_store", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := NewMockUserStore(ctrl)
+		store.EXPECT().GetUser(gomock.Any(), 1).Return(User{}, ErrUserNotFound)
+
+		receiver := NewStoreServer(store)
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/1", reqReader)
+		req.SetPathValue("id", "1")
+
+		rr := httptest.NewRecorder()
+		receiver.GetUser(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("StoreServer.GetUser returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+
+		expectedResponse := ErrorResponse{
+			Error: "User not found",
+			Code:  "NOT_FOUND",
+		}
+
+		var actualResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
+			t.Fatalf("Failed to unmarshal actual response: %v", err)
+		}
+
+		if expectedResponse.Error != actualResponse.Error {
+			t.Errorf("StoreServer.GetUser field Error mismatch:\ngot:  %q\nwant: %q", actualResponse.Error, expectedResponse.Error)
+		}
+		if expectedResponse.Code != actualResponse.Code {
+			t.Errorf("StoreServer.GetUser field Code mismatch:\ngot:  %q\nwant: %q", actualResponse.Code, expectedResponse.Code)
+		}
+	})
+
+	t.Run("it_should_return_an_internal_error_when_the_store_fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := NewMockUserStore(ctrl)
+		store.EXPECT().GetUser(gomock.Any(), 1).Return(User{}, errors.New("connection refused"))
+
+		receiver := NewStoreServer(store)
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/1", reqReader)
+		req.SetPathValue("id", "1")
+
+		rr := httptest.NewRecorder()
+		receiver.GetUser(rr, req)
+
+		if status := rr.Code; status != http.StatusInternalServerError {
+			t.Errorf("StoreServer.GetUser returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+		}
+
+		expectedResponse := ErrorResponse{
+			Error: "Could not get user",
+			Code:  "INTERNAL",
 		}
-		actualJSON, err := json.Marshal(actual)
+
+		var actualResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &actualResponse); err != nil {
+			t.Fatalf("Failed to unmarshal actual response: %v", err)
+		}
+
+		if expectedResponse.Error != actualResponse.Error {
+			t.Errorf("StoreServer.GetUser field Error mismatch:\ngot:  %q\nwant: %q", actualResponse.Error, expectedResponse.Error)
+		}
+		if expectedResponse.Code != actualResponse.Code {
+			t.Errorf("StoreServer.GetUser field Code mismatch:\ngot:  %q\nwant: %q", actualResponse.Code, expectedResponse.Code)
+		}
+	})
+
+	t.Run("it_should_not_query_the_store_for_invalid_IDs", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := NewMockUserStore(ctrl)
+
+		receiver := NewStoreServer(store)
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/me", reqReader)
+		req.SetPathValue("id", "me")
+
+		rr := httptest.NewRecorder()
+		receiver.GetUser(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("StoreServer.GetUser returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+}
+func TestRouter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("it_should_route_users_by_id", func(t *testing.T) {
+		router := NewRouter()
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/users/123", reqReader)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Router returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		problems, err := matchJSON(rr.Body.Bytes(), `{"email":{"$regex":"@example\\.com$"},"id":{"$type":"number"}}`, jsonMatch{Subset: true})
 		if err != nil {
-			t.Fatalf("unexpected error while json marshalling actual json: %v\n", err)
+			t.Fatalf("Failed to unmarshal actual response: %v", err)
+		}
+		if len(problems) > 0 {
+			t.Errorf("Router returned unexpected body:\n%s", strings.Join(problems, "\n"))
 		}
 
-		if string(expectedJSON) != string(actualJSON) {
-			t.Errorf("HealthCheckHandler returned unexpected body:\ngot:  %s\nwant: %s", string(actualJSON), string(expectedJSON))
+		for _, problem := range assertJSON(rr.Body.Bytes(), []jsonAssertion{
+			{Path: "$.name", Op: "length", Value: `10`},
+		}) {
+			t.Errorf("Router returned unexpected body: %s", problem)
 		}
 	})
+
+	t.Run("it_should_not_allow_deleting_users", func(t *testing.T) {
+		router := NewRouter()
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "DELETE", "/users/123", reqReader)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Errorf("Router returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+		}
+
+		// Result returns the headers as they were when the status code was written.
+		headers := rr.Result().Header
+		if got, want := headers.Values("Allow"), []string{"GET, HEAD"}; !slices.Equal(got, want) {
+			t.Errorf("Router returned wrong %s header: got %q want %q", "Allow", got, want)
+		}
+	})
+
+	t.Run("it_should_return_not_found_for_unknown_paths", func(t *testing.T) {
+		router := NewRouter()
+
+		var reqReader io.Reader = nil
+		req := httptest.NewRequestWithContext(ctx, "GET", "/unknown", reqReader)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Router returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+}
+
+// jsonMatch relaxes the comparison of a JSON body with the expected one.
+// Subset allows objects to hold keys the expected ones leave out, Ignore lists the paths whose values are not compared
+// and Unordered the paths of the arrays whose items may be in any order. In paths, * matches any key and [*] any index.
+type jsonMatch struct {
+	Subset    bool
+	Ignore    []string
+	Unordered []string
+}
+
+// matchJSON compares a JSON body with the expected one and returns the differences, prefixed by their path.
+// Expected values can be matchers: {"$any": true} matches any value, {"$regex": "expr"} strings matching a regular expression,
+// {"$type": "number"} values of a JSON type and {"$rfc3339": true} RFC 3339 timestamps.
+func matchJSON(body []byte, expected string, match jsonMatch) ([]string, error) {
+	want, err := decodeJSON([]byte(expected))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected body: %w", err)
+	}
+	got, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := jsonMatcher{subset: match.Subset}
+	for _, path := range match.Ignore {
+		m.ignore = append(m.ignore, jsonPathRegexp(path))
+	}
+	for _, path := range match.Unordered {
+		m.unordered = append(m.unordered, jsonPathRegexp(path))
+	}
+	return m.compare("$", want, got), nil
+}
+
+// jsonMatcher compares JSON values with expected ones, see matchJSON
+type jsonMatcher struct {
+	subset    bool
+	ignore    []*regexp.Regexp
+	unordered []*regexp.Regexp
+}
+
+func (m jsonMatcher) compare(path string, want, got any) []string {
+	if matchesJSONPath(m.ignore, path) {
+		return nil
+	}
+
+	if obj, ok := want.(map[string]any); ok && len(obj) == 1 {
+		for matcher, arg := range obj {
+			switch matcher {
+			case "$any":
+				return nil
+			case "$regex":
+				if s, ok := got.(string); ok && regexp.MustCompile(arg.(string)).MatchString(s) {
+					return nil
+				}
+				return []string{fmt.Sprintf("%s: got %s, want a string matching %q", path, jsonText(got), arg)}
+			case "$type":
+				if jsonType(got) == arg {
+					return nil
+				}
+				return []string{fmt.Sprintf("%s: got %s, want a %s", path, jsonText(got), arg)}
+			case "$rfc3339":
+				if s, ok := got.(string); ok {
+					if _, err := time.Parse(time.RFC3339, s); err == nil {
+						return nil
+					}
+				}
+				return []string{fmt.Sprintf("%s: got %s, want an RFC 3339 timestamp", path, jsonText(got))}
+			}
+		}
+	}
+
+	switch want := want.(type) {
+	case map[string]any:
+		obj, ok := got.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %s, want an object", path, jsonText(got))}
+		}
+
+		var problems []string
+		for _, key := range slices.Sorted(maps.Keys(want)) {
+			value, ok := obj[key]
+			if !ok && !matchesJSONPath(m.ignore, path+"."+key) {
+				problems = append(problems, fmt.Sprintf("%s.%s: missing, want %s", path, key, jsonText(want[key])))
+				continue
+			}
+			problems = append(problems, m.compare(path+"."+key, want[key], value)...)
+		}
+		for _, key := range slices.Sorted(maps.Keys(obj)) {
+			if _, ok := want[key]; !ok && !m.subset && !matchesJSONPath(m.ignore, path+"."+key) {
+				problems = append(problems, fmt.Sprintf("%s.%s: got unexpected %s", path, key, jsonText(obj[key])))
+			}
+		}
+		return problems
+	case []any:
+		items, ok := got.([]any)
+		if !ok || len(items) != len(want) {
+			return []string{fmt.Sprintf("%s: got %s, want %d item(s)", path, jsonText(got), len(want))}
+		}
+
+		var problems []string
+		if !matchesJSONPath(m.unordered, path) {
+			for i := range want {
+				problems = append(problems, m.compare(path+"["+strconv.Itoa(i)+"]", want[i], items[i])...)
+			}
+			return problems
+		}
+
+		// Items can match several expected items, e.g. through matchers, so expected items are assigned
+		// distinct items along augmenting paths, reassigning the items already taken when needed
+		matches := make([][]bool, len(want))
+		for i, wantItem := range want {
+			matches[i] = make([]bool, len(items))
+			for j, item := range items {
+				matches[i][j] = len(m.compare(path+"["+strconv.Itoa(j)+"]", wantItem, item)) == 0
+			}
+		}
+
+		assigned := make([]int, len(items))
+		for j := range assigned {
+			assigned[j] = -1
+		}
+		var assign func(i int, visited []bool) bool
+		assign = func(i int, visited []bool) bool {
+			for j := range items {
+				if matches[i][j] && !visited[j] {
+					visited[j] = true
+					if assigned[j] == -1 || assign(assigned[j], visited) {
+						assigned[j] = i
+						return true
+					}
+				}
+			}
+			return false
+		}
+
+		for i, wantItem := range want {
+			if !assign(i, make([]bool, len(items))) {
+				problems = append(problems, fmt.Sprintf("%s: no item matches %s", path, jsonText(wantItem)))
+			}
+		}
+		return problems
+	case json.Number:
+		if n, ok := got.(json.Number); !ok || compareJSONNumbers(n, want) != 0 {
+			return []string{fmt.Sprintf("%s: got %s, want %s", path, jsonText(got), jsonText(want))}
+		}
+		return nil
+	default:
+		if want != got {
+			return []string{fmt.Sprintf("%s: got %s, want %s", path, jsonText(got), jsonText(want))}
+		}
+		return nil
+	}
+}
+
+// jsonPathRegexp compiles a JSON path such as $.items[*].id into a regular expression matching the paths it designates
+func jsonPathRegexp(path string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(path)
+	expr = strings.ReplaceAll(expr, `\[\*\]`, `\[[0-9]+\]`)
+	expr = strings.ReplaceAll(expr, `\.\*`, `\.[^.\[]+`)
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// matchesJSONPath reports whether a path matches any of the compiled JSON paths
+func matchesJSONPath(paths []*regexp.Regexp, path string) bool {
+	return slices.ContainsFunc(paths, func(re *regexp.Regexp) bool { return re.MatchString(path) })
+}
+
+// jsonType returns the JSON type of a decoded value
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonAssertion checks the value at a JSON path of a body, such as $.items[0].id, with an operator:
+// ==, !=, >, >=, <, <=, contains, length or exists. Value is the JSON encoded operand.
+type jsonAssertion struct {
+	Path  string
+	Op    string
+	Value string
+}
+
+// assertJSON checks assertions on a JSON body and returns the failed ones, with their path and the expected and actual values.
+func assertJSON(body []byte, assertions []jsonAssertion) []string {
+	doc, err := decodeJSON(body)
+	if err != nil {
+		return []string{fmt.Sprintf("could not unmarshal %q: %v", body, err)}
+	}
+
+	var problems []string
+	for _, assertion := range assertions {
+		want, err := decodeJSON([]byte(assertion.Value))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid value %s: %v", assertion.Path, assertion.Value, err))
+			continue
+		}
+
+		got, found := jsonPathValue(doc, assertion.Path)
+		switch {
+		case assertion.Op == "exists":
+			if found != want {
+				problems = append(problems, fmt.Sprintf("%s: got exists %t, want exists %s", assertion.Path, found, assertion.Value))
+			}
+		case !found:
+			problems = append(problems, fmt.Sprintf("%s: missing, want %s %s", assertion.Path, assertion.Op, assertion.Value))
+		default:
+			if actual, ok := checkJSONAssertion(assertion.Op, got, want); !ok {
+				problems = append(problems, fmt.Sprintf("%s: got %s, want %s %s", assertion.Path, actual, assertion.Op, assertion.Value))
+			}
+		}
+	}
+	return problems
+}
+
+// checkJSONAssertion applies the operator of an assertion to a value, returning the value as it is described on failure
+func checkJSONAssertion(op string, got, want any) (string, bool) {
+	switch op {
+	case "==":
+		return jsonText(got), equalJSON(got, want)
+	case "!=":
+		return jsonText(got), !equalJSON(got, want)
+	case ">", ">=", "<", "<=":
+		g, isNumber := got.(json.Number)
+		w, _ := want.(json.Number)
+		if !isNumber {
+			return jsonText(got), false
+		}
+		cmp := compareJSONNumbers(g, w)
+		return jsonText(got), op == ">" && cmp > 0 || op == ">=" && cmp >= 0 || op == "<" && cmp < 0 || op == "<=" && cmp <= 0
+	case "contains":
+		switch got := got.(type) {
+		case string:
+			s, ok := want.(string)
+			return jsonText(got), ok && strings.Contains(got, s)
+		case []any:
+			return jsonText(got), slices.ContainsFunc(got, func(item any) bool { return equalJSON(item, want) })
+		case map[string]any:
+			key, ok := want.(string)
+			_, found := got[key]
+			return jsonText(got), ok && found
+		}
+	case "length":
+		var n int
+		switch got := got.(type) {
+		case string:
+			n = len([]rune(got))
+		case []any:
+			n = len(got)
+		case map[string]any:
+			n = len(got)
+		default:
+			return jsonText(got), false
+		}
+		w, _ := want.(json.Number)
+		return fmt.Sprintf("length %d", n), w == json.Number(strconv.Itoa(n))
+	}
+	return jsonText(got), false
+}
+
+// equalJSON reports whether two decoded JSON values are equal, comparing numbers by value
+func equalJSON(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		return ok && compareJSONNumbers(a, b) == 0
+	case []any:
+		b, ok := b.([]any)
+		return ok && slices.EqualFunc(a, b, equalJSON)
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		return ok && maps.EqualFunc(a, b, equalJSON)
+	default:
+		return a == b
+	}
+}
+
+// jsonPathValue returns the value at a JSON path of keys and indexes, such as $.items[0].id, and whether it exists
+func jsonPathValue(value any, path string) (any, bool) {
+	rest := strings.TrimPrefix(path, "$")
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			i, err := strconv.Atoi(rest[1:end])
+			items, ok := value.([]any)
+			if err != nil || !ok || i >= len(items) {
+				return nil, false
+			}
+			value, rest = items[i], rest[end+1:]
+			continue
+		}
+
+		end := strings.IndexAny(rest[1:], ".[") + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[rest[1:end]]; !ok {
+			return nil, false
+		}
+		rest = rest[end:]
+	}
+	return value, true
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Number so that large integers are compared exactly
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the JSON value")
+	}
+	return v, nil
+}
+
+// compareJSONNumbers compares two decoded JSON numbers, returning -1, 0 or +1 as big.Rat.Cmp does
+func compareJSONNumbers(a, b json.Number) int {
+	x, _ := new(big.Rat).SetString(a.String())
+	y, _ := new(big.Rat).SetString(b.String())
+	return x.Cmp(y)
+}
+
+// jsonText returns the JSON encoding of a decoded value
+func jsonText(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// init registers the -update flag, rewriting the golden files with the bodies returned by the handlers.
+// Packages declaring their own -update flag at package level, as in the standard golden file idiom, share it:
+// package level variables are initialized before init functions run.
+func init() {
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "update the golden files of the tests")
+	}
+}
+
+// assertGolden compares a response body with the golden file at path, writing the file first when -update is set.
+// JSON bodies are stored indented and compared compacted.
+func assertGolden(t *testing.T, path string, body []byte) {
+	t.Helper()
+
+	if flag.Lookup("update").Value.String() == "true" {
+		content := body
+		var indented bytes.Buffer
+		// json.Encoder terminates bodies with a newline, trimmed so that golden files end with a single one
+		if err := json.Indent(&indented, bytes.TrimRight(body, " \t\r\n"), "", "  "); err == nil {
+			content = append(indented.Bytes(), '\n')
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("could not create the directory of %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("could not update %s: %v", path, err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read golden file, run the tests with -update to create it: %v", err)
+	}
+
+	var got, expected bytes.Buffer
+	if json.Compact(&got, body) == nil && json.Compact(&expected, want) == nil {
+		body, want = got.Bytes(), expected.Bytes()
+	}
+	if !bytes.Equal(body, want) {
+		t.Errorf("response body does not match %s, run the tests with -update to accept it:\ngot:  %s\nwant: %s", path, body, want)
+	}
 }
@@ -0,0 +1,28 @@
+package handler
+
+type (
+	// User represents a user in our system
+	User struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	// CreateUserRequest represents the request payload for creating a user
+	CreateUserRequest struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	// CreateUserResponse represents the response when creating a user
+	CreateUserResponse struct {
+		User    User   `json:"user"`
+		Message string `json:"message"`
+	}
+
+	// ErrorResponse represents an error response
+	ErrorResponse struct {
+		Error   string `json:"error"`
+		Code    string `json:"code"`
+		Details string `json:"details,omitempty"`
+	}
+)
@@ -1,3 +1,18 @@
 module github.com/andream16/gophercon-tutorial/httptestgen
 
 go 1.24.3
+
+require (
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.2
+	go.uber.org/mock v0.5.2
+	go.yaml.in/yaml/v3 v3.0.4
+	golang.org/x/text v0.14.0
+	golang.org/x/tools v0.36.0
+)
+
+require (
+	golang.org/x/mod v0.28.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
+)
+
+tool go.uber.org/mock/mockgen